@@ -1,46 +1,632 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"github.com/gorilla/mux"
+	"io/ioutil"
+	"math"
 	"net/http"
+	"os"
+	"os/signal"
 	"sketch-go-course/pkg/location"
 	"sketch-go-course/pkg/weather"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	_ "time/tzdata"
 )
 
-func main() {
+type server struct {
+	zips          location.Resolver
+	reloader      *location.Reloader
+	weatherClient weather.Client
+
+	// adminToken guards /admin/ endpoints, which are not served without one.
+	adminToken string
+}
+
+// forecastResponse is the forecast summary with the location it is for. The
+// summary is embedded so its fields stay at the top level of the JSON.
+type forecastResponse struct {
+	weather.ForecastSummary
+	Location locationResponse `json:"location"`
+	// Cache is whether the upstream forecast was a cache hit, revalidated
+	// or a miss.
+	Cache weather.CacheStatus `json:"cache,omitempty"`
+}
+
+type locationResponse struct {
+	Country     string  `json:"country"`
+	Zip         string  `json:"zip"`
+	Lat         float64 `json:"lat"`
+	Long        float64 `json:"long"`
+	City        string  `json:"city,omitempty"`
+	State       string  `json:"state,omitempty"`
+	CountyFIPS  string  `json:"countyFips,omitempty"`
+	TimeZone    string  `json:"timeZone,omitempty"`
+	Approximate bool    `json:"approximate,omitempty"`
+	// FromCoordinate is set when the request gave a coordinate; Zip is then
+	// the nearest ZIP code, if any, and Lat/Long are the given point.
+	FromCoordinate bool `json:"fromCoordinate,omitempty"`
+}
+
+type cellResponse struct {
+	Geohash  string                  `json:"geohash"`
+	South    float64                 `json:"south"`
+	West     float64                 `json:"west"`
+	North    float64                 `json:"north"`
+	East     float64                 `json:"east"`
+	Zips     int                     `json:"zips"`
+	Location locationResponse        `json:"location"`
+	Forecast weather.ForecastSummary `json:"forecast"`
+	Cache    weather.CacheStatus     `json:"cache,omitempty"`
+	Error    string                  `json:"error,omitempty"`
+}
+
+// maxCells caps how many upstream forecasts one /geohash/forecasts request
+// may fetch.
+const maxCells = 25
+
+type suggestionResponse struct {
+	City  string   `json:"city"`
+	State string   `json:"state"`
+	Zips  []string `json:"zips"`
+	Lat   float64  `json:"lat"`
+	Long  float64  `json:"long"`
+	Match string   `json:"match"`
+}
+
+type reloadResponse struct {
+	Source   string    `json:"source"`
+	Rows     int       `json:"rows"`
+	LoadTime string    `json:"loadTime"`
+	LoadedAt time.Time `json:"loadedAt"`
+	Error    string    `json:"error,omitempty"`
+}
+
+func (s server) routes() *mux.Router {
 
 	router := mux.NewRouter()
 
-	zipCodeMap, _ := location.LoadZipCodeMap("zip.csv")
+	// ?country= selects the postal code format, US by default. A coordinate
+	// such as 38.67,-90.37 is accepted in place of a ZIP code, or in any
+	// form location.ParseLatLong understands as /forecast?at=
+	router.HandleFunc("/forecast/{zipcode}.geojson", s.handleForecastGeoJSON)
+	router.HandleFunc("/forecast/{zipcode}", s.handleForecast)
+	router.HandleFunc("/forecast", s.handleForecast).Queries("at", "{at}")
+	router.HandleFunc("/geohash/forecasts", s.handleCellForecasts).Methods(http.MethodGet)
+	router.HandleFunc("/places", s.handlePlaces).Methods(http.MethodGet)
+	router.HandleFunc("/zips.geojson", s.handleZipsGeoJSON).Methods(http.MethodGet)
+	router.HandleFunc("/zips.geojson", s.handleZipsInArea).Methods(http.MethodPost)
+
+	if s.adminToken != "" {
+		router.HandleFunc("/admin/reload", s.requireAdmin(s.handleReload)).Methods(http.MethodPost)
+	}
+
+	return router
+}
+
+func (s server) handleForecast(writer http.ResponseWriter, request *http.Request) {
+
+	response, ok := s.forecast(writer, request)
+
+	if !ok {
+		return
+	}
+
+	b, _ := json.Marshal(response)
+
+	writer.Header().Add("content-type", "application/json")
+	_, _ = writer.Write(b)
+}
+
+// handleForecastGeoJSON serves the forecast as a GeoJSON point feature with
+// the location and days as properties.
+func (s server) handleForecastGeoJSON(writer http.ResponseWriter, request *http.Request) {
+
+	response, ok := s.forecast(writer, request)
+
+	if !ok {
+		return
+	}
+
+	properties := map[string]interface{}{
+		"location": response.Location,
+	}
+
+	if response.Cache != "" {
+		properties["cache"] = response.Cache
+	}
+
+	coordinate := location.Coordinate{Lat: response.Location.Lat, Long: response.Location.Long}
+	b, _ := json.Marshal(response.ForecastSummary.Feature(coordinate, properties))
+
+	writer.Header().Add("content-type", location.GeoJSONContentType)
+	_, _ = writer.Write(b)
+}
+
+// forecast resolves the request's location and fetches its forecast. If
+// that fails it writes the error response and returns false.
+func (s server) forecast(writer http.ResponseWriter, request *http.Request) (forecastResponse, bool) {
+
+	country := location.US
+
+	if countryStr := request.URL.Query().Get("country"); countryStr != "" {
+		parsed, countryErr := location.ParseCountry(countryStr)
+		if countryErr != nil {
+			http.Error(writer, countryErr.Error(), http.StatusBadRequest)
+			return forecastResponse{}, false
+		}
+		country = parsed
+	}
+
+	vars := mux.Vars(request)
+	resolution, resolveErr := s.resolve(country, vars)
+
+	switch {
+	case errors.Is(resolveErr, location.ErrUnrecognizedCoordinate), errors.Is(resolveErr, location.ErrAmbiguousCoordinate),
+		errors.Is(resolveErr, location.ErrInvalidLatitude), errors.Is(resolveErr, location.ErrInvalidLongitude),
+		errors.Is(resolveErr, location.ErrInvalidZip), errors.Is(resolveErr, location.ErrInvalidPostalCode):
+		http.Error(writer, resolveErr.Error(), http.StatusBadRequest)
+		return forecastResponse{}, false
+	case resolveErr != nil:
+		http.Error(writer, resolveErr.Error(), http.StatusNotFound)
+		return forecastResponse{}, false
+	}
+
+	// the forecast is abandoned if the caller goes away
+	forecast, fetchErr := s.weatherClient.FetchForecastContext(request.Context(), resolution.Coordinate)
+
+	if fetchErr != nil {
+		fmt.Println("Could not get forecast ", fetchErr)
+		writeFetchError(writer, fetchErr)
+		return forecastResponse{}, false
+	}
+
+	// without a time zone the days are grouped as upstream reported them
+	var timeZone string
+	loc, zoneErr := location.LoadPlaceTimeZone(resolution.Place)
+
+	if zoneErr != nil {
+		loc = nil
+	} else {
+		timeZone = loc.String()
+	}
+
+	return forecastResponse{
+		ForecastSummary: forecast.SummaryIn(loc),
+		Location: locationResponse{
+			Country:        string(resolution.Place.Country),
+			Zip:            resolution.Zip,
+			Lat:            resolution.Coordinate.Lat,
+			Long:           resolution.Coordinate.Long,
+			City:           resolution.Place.City,
+			State:          resolution.Place.State,
+			CountyFIPS:     resolution.Place.CountyFIPS,
+			TimeZone:       timeZone,
+			Approximate:    resolution.Approximate,
+			FromCoordinate: resolution.FromCoordinate,
+		},
+		Cache: forecast.Cache,
+	}, true
+}
+
+// writeFetchError answers for a failed upstream forecast request: 404 when
+// upstream has no forecast for the point, 503 with Retry-After when it is
+// rate limiting us, 504 when it could not be reached, and 502 otherwise.
+func writeFetchError(writer http.ResponseWriter, err error) {
+
+	status := http.StatusBadGateway
+
+	var upstreamErr *weather.UpstreamError
+
+	switch {
+	case errors.Is(err, weather.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, weather.ErrRateLimited):
+		status = http.StatusServiceUnavailable
+		if errors.As(err, &upstreamErr) && upstreamErr.RetryAfter > 0 {
+			writer.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(upstreamErr.RetryAfter.Seconds()))))
+		}
+	case errors.Is(err, weather.ErrNetwork):
+		status = http.StatusGatewayTimeout
+	}
+
+	http.Error(writer, err.Error(), status)
+}
+
+// handleZipsGeoJSON serves the ZIP codes in ?bbox=west,south,east,north as a
+// GeoJSON feature collection of points.
+func (s server) handleZipsGeoJSON(writer http.ResponseWriter, request *http.Request) {
+
+	boxResolver, ok := s.zips.(location.BoxResolver)
+
+	if !ok {
+		http.Error(writer, "bounding box queries are not supported by this dataset", http.StatusNotImplemented)
+		return
+	}
+
+	box, boxErr := location.ParseBoundingBox(request.URL.Query().Get("bbox"))
+
+	if boxErr != nil {
+		http.Error(writer, boxErr.Error(), http.StatusBadRequest)
+		return
+	}
+
+	places := location.MatchPlaces(s.zips, boxResolver.InBox(box))
+	b, _ := json.Marshal(location.PlacesGeoJSON(places).WithBBox(box))
+
+	writer.Header().Add("content-type", location.GeoJSONContentType)
+	_, _ = writer.Write(b)
+}
+
+// resolve finds the location a forecast request is for: the ?at= coordinate,
+// or the path segment as a postal code and, failing that, as a coordinate.
+func (s server) resolve(country location.Country, vars map[string]string) (location.Resolution, error) {
+
+	if at, ok := vars["at"]; ok {
+		c, parseErr := location.ParseLatLong(at)
+		if parseErr != nil {
+			return location.Resolution{}, parseErr
+		}
+		return location.ResolveCoordinate(s.zips, c), nil
+	}
+
+	resolution, resolveErr := location.ResolvePostalCode(s.zips, country, vars["zipcode"])
+
+	if errors.Is(resolveErr, location.ErrInvalidZip) || errors.Is(resolveErr, location.ErrInvalidPostalCode) {
+		if c, parseErr := location.ParseLatLong(vars["zipcode"]); parseErr == nil {
+			return location.ResolveCoordinate(s.zips, c), nil
+		} else if errors.Is(parseErr, location.ErrAmbiguousCoordinate) {
+			return location.Resolution{}, parseErr
+		}
+	}
+
+	return resolution, resolveErr
+}
+
+// handleCellForecasts groups the ZIP codes in ?bbox=west,south,east,north by
+// geohash of ?precision= characters, 4 by default, and serves one forecast
+// per cell for the ZIP code nearest its center.
+func (s server) handleCellForecasts(writer http.ResponseWriter, request *http.Request) {
+
+	boxResolver, ok := s.zips.(location.BoxResolver)
+
+	if !ok {
+		http.Error(writer, "bounding box queries are not supported by this dataset", http.StatusNotImplemented)
+		return
+	}
+
+	box, boxErr := location.ParseBoundingBox(request.URL.Query().Get("bbox"))
+
+	if boxErr != nil {
+		http.Error(writer, boxErr.Error(), http.StatusBadRequest)
+		return
+	}
+
+	precision := 4
+
+	if precisionStr := request.URL.Query().Get("precision"); precisionStr != "" {
+		parsed, parseErr := strconv.Atoi(precisionStr)
+		if parseErr != nil {
+			http.Error(writer, "precision must be a number", http.StatusBadRequest)
+			return
+		}
+		precision = parsed
+	}
+
+	cells, groupErr := location.GroupByGeohash(boxResolver.InBox(box), precision)
 
-	weatherClient := weather.Client{
-		Client: &http.Client{},
+	if groupErr != nil {
+		http.Error(writer, groupErr.Error(), http.StatusBadRequest)
+		return
 	}
 
-	router.HandleFunc("/forecast/{zipcode}", func(writer http.ResponseWriter, request *http.Request) {
+	if len(cells) > maxCells {
+		http.Error(writer, fmt.Sprintf("%d cells in box, at most %d allowed: use a smaller box or precision", len(cells), maxCells), http.StatusBadRequest)
+		return
+	}
+
+	response := make([]cellResponse, 0, len(cells))
+
+	for _, cell := range cells {
 
-		vars := mux.Vars(request)
-		zip := vars["zipcode"]
-		coords := zipCodeMap[zip]
+		if request.Context().Err() != nil {
+			return
+		}
+
+		representative := cell.Matches[0]
 
-		forecast, fetchErr := weatherClient.FetchForecast(coords)
+		cellForecast := cellResponse{
+			Geohash: cell.Hash,
+			South:   cell.Bounds.South,
+			West:    cell.Bounds.West,
+			North:   cell.Bounds.North,
+			East:    cell.Bounds.East,
+			Zips:    len(cell.Matches),
+			Location: locationResponse{
+				Country: string(representative.Country),
+				Zip:     representative.Zip,
+				Lat:     representative.Coordinate.Lat,
+				Long:    representative.Coordinate.Long,
+			},
+		}
+
+		forecast, fetchErr := s.weatherClient.FetchForecastContext(request.Context(), representative.Coordinate)
 
 		if fetchErr != nil {
-			fmt.Println("Could not get forecast ", fetchErr)
+			cellForecast.Error = fetchErr.Error()
+			response = append(response, cellForecast)
+			continue
+		}
+
+		// without a time zone the days are grouped as upstream reported them
+		loc, zoneErr := location.LoadPlaceTimeZone(location.ResolveCoordinate(s.zips, representative.Coordinate).Place)
+
+		if zoneErr != nil {
+			loc = nil
+		} else {
+			cellForecast.Location.TimeZone = loc.String()
+		}
+
+		cellForecast.Forecast = forecast.SummaryIn(loc)
+		cellForecast.Cache = forecast.Cache
+		response = append(response, cellForecast)
+	}
+
+	b, _ := json.Marshal(response)
+
+	writer.Header().Add("content-type", "application/json")
+	_, _ = writer.Write(b)
+}
+
+// maxAreaBytes caps the size of a GeoJSON body posted to /zips.geojson.
+const maxAreaBytes = 4 << 20
+
+// handleZipsInArea serves the ZIP codes inside the polygons of a GeoJSON
+// geometry, feature or feature collection posted as the body, such as a
+// weather alert, as a GeoJSON feature collection of points.
+func (s server) handleZipsInArea(writer http.ResponseWriter, request *http.Request) {
+
+	areaResolver, ok := s.zips.(location.AreaResolver)
+
+	if !ok {
+		http.Error(writer, "area queries are not supported by this dataset", http.StatusNotImplemented)
+		return
+	}
+
+	body, readErr := ioutil.ReadAll(http.MaxBytesReader(writer, request.Body, maxAreaBytes))
+
+	if readErr != nil {
+		http.Error(writer, readErr.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	area, areaErr := location.ParseArea(body)
+
+	if areaErr != nil {
+		http.Error(writer, areaErr.Error(), http.StatusBadRequest)
+		return
+	}
+
+	places := location.MatchPlaces(s.zips, areaResolver.InArea(area))
+	b, _ := json.Marshal(location.PlacesGeoJSON(places))
+
+	writer.Header().Add("content-type", location.GeoJSONContentType)
+	_, _ = writer.Write(b)
+}
+
+// handlePlaces serves type-ahead suggestions for ?q=, e.g. "spring" or
+// "Springfield, IL", best match first.
+func (s server) handlePlaces(writer http.ResponseWriter, request *http.Request) {
+
+	searcher, ok := s.zips.(location.Searcher)
+
+	if !ok {
+		http.Error(writer, "place search is not supported by this dataset", http.StatusNotImplemented)
+		return
+	}
+
+	if searcher.PlaceNames() == 0 {
+		http.Error(writer, "the ZIP code dataset has no place names: use one with city and state columns, such as zipimport -names writes", http.StatusNotImplemented)
+		return
+	}
+
+	limit := 10
+
+	if limitStr := request.URL.Query().Get("limit"); limitStr != "" {
+		parsed, parseErr := strconv.Atoi(limitStr)
+		if parseErr != nil || parsed < 1 || parsed > 50 {
+			http.Error(writer, "limit must be between 1 and 50", http.StatusBadRequest)
 			return
 		}
+		limit = parsed
+	}
 
-		b, _ := json.Marshal(forecast.Summary())
+	suggestions := searcher.Suggest(request.URL.Query().Get("q"), limit)
+	response := make([]suggestionResponse, 0, len(suggestions))
 
-		writer.Header().Add("content-type", "application/json")
-		_, _ = writer.Write(b)
+	for _, suggestion := range suggestions {
+		response = append(response, suggestionResponse{
+			City:  suggestion.City,
+			State: suggestion.State,
+			Zips:  suggestion.Zips,
+			Lat:   suggestion.Coordinate.Lat,
+			Long:  suggestion.Coordinate.Long,
+			Match: suggestion.Match.String(),
+		})
+	}
 
-	})
+	b, _ := json.Marshal(response)
+
+	writer.Header().Add("content-type", "application/json")
+	_, _ = writer.Write(b)
+}
 
-	server := &http.Server{Handler: router,
-		Addr: ":8000"}
-	server.ListenAndServe()
+// requireAdmin serves next only to requests carrying the admin token as
+// "Authorization: Bearer <token>".
+func (s server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+
+		token := strings.TrimPrefix(request.Header.Get("Authorization"), "Bearer ")
+
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+			writer.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(writer, "admin token required", http.StatusUnauthorized)
+			return
+		}
+
+		next(writer, request)
+	}
+}
+
+// handleReload reloads the ZIP code dataset and reports what is being served
+// afterwards. If the reload fails the previous dataset is still served.
+func (s server) handleReload(writer http.ResponseWriter, request *http.Request) {
+
+	status := http.StatusOK
+	var response reloadResponse
+
+	stats, reloadErr := s.reloader.Reload()
+
+	if reloadErr != nil {
+		fmt.Println("Could not reload zip codes ", reloadErr)
+		status = http.StatusInternalServerError
+		stats = s.reloader.Stats()
+		response.Error = reloadErr.Error()
+	}
+
+	response.Source = stats.Source
+	response.Rows = stats.Rows
+	response.LoadTime = stats.LoadTime.String()
+	response.LoadedAt = stats.LoadedAt
+
+	b, _ := json.Marshal(response)
+
+	writer.Header().Add("content-type", "application/json")
+	writer.WriteHeader(status)
+	_, _ = writer.Write(b)
+}
+
+func main() {
+
+	zipSource := flag.String("zips", "zip.csv", "ZIP code dataset: a CSV file or \""+location.BuiltinSource+"\"; "+location.SnapshotExt+" snapshots lack the place names and time zones the server uses")
+	addr := flag.String("addr", ":8000", "address to listen on")
+	precision := flag.Int("precision", weather.DefaultPrecision, "decimal places coordinates are rounded to before calling upstream, or -1 to send them unchanged")
+	upstreamTimeout := flag.Duration("upstream-timeout", 10*time.Second, "time allowed for each forecast lookup upstream, 0 for no limit")
+	retries := flag.Int("retries", weather.DefaultRetryPolicy.MaxAttempts, "most attempts made at each upstream request, counting the first")
+	upstreamRate := flag.Float64("upstream-rps", 5, "most upstream requests per second, 0 for no limit")
+	upstreamBurst := flag.Int("upstream-burst", 10, "upstream requests allowed at once before -upstream-rps applies")
+	upstreamInFlight := flag.Int("upstream-concurrency", 8, "most upstream requests in flight at once, 0 for no limit")
+	pointsCache := flag.String("points-cache", "", "file caching the forecast grid point of each location across restarts, empty to cache in memory")
+	pointsCacheSize := flag.Int("points-cache-size", 10000, "grid points kept in memory or in -points-cache")
+	pointsCacheFlush := flag.Duration("points-cache-flush", time.Minute, "how often new grid points are written to -points-cache")
+	pointsTTL := flag.Duration("points-ttl", weather.DefaultPointsTTL, "how long a cached grid point is trusted")
+	httpCacheSize := flag.Int("http-cache-size", weather.DefaultCacheEntries, "upstream responses cached by their Cache-Control, Expires and validators, 0 to disable")
+	adminToken := flag.String("admin-token", os.Getenv("ADMIN_TOKEN"), "bearer token for POST /admin/reload, which is disabled without one; defaults to $ADMIN_TOKEN")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 10*time.Second, "time allowed for requests in flight to finish on SIGINT or SIGTERM")
+	reloadInterval := flag.Duration("reload-interval", 10*time.Second, "how often to check the ZIP code dataset for changes, 0 to disable")
+	flag.Parse()
+
+	retry := weather.DefaultRetryPolicy
+	retry.MaxAttempts = *retries
+
+	// SIGINT and SIGTERM shut the server down, letting requests in flight
+	// finish and the points cache be saved
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	var background sync.WaitGroup
+	defer func() {
+		stop()
+		background.Wait()
+	}()
+
+	upstream := &http.Client{}
+
+	if *httpCacheSize > 0 {
+		upstream.Transport = weather.NewCacheTransport(nil, *httpCacheSize)
+	}
+
+	var points weather.PointsCache = weather.NewLRUPointsCache(*pointsCacheSize)
+
+	if *pointsCache != "" {
+		fileCache, err := weather.OpenFilePointsCache(*pointsCache, *pointsCacheSize, time.Now())
+
+		if err != nil {
+			fmt.Println("Could not open points cache ", err)
+			return
+		}
+
+		// flushes once more when ctx ends, before main returns
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			fileCache.FlushEvery(ctx, *pointsCacheFlush, func(err error) {
+				fmt.Println("Could not save points cache ", err)
+			})
+		}()
+
+		points = fileCache
+	}
+
+	reloader, zipCodeErr := location.NewReloader(*zipSource)
+
+	if zipCodeErr != nil {
+		fmt.Println("Could not load zip codes ", zipCodeErr)
+		return
+	}
+
+	if *reloadInterval > 0 {
+		go reloader.Watch(ctx, *reloadInterval, func(stats location.ReloadStats, err error) {
+			if err != nil {
+				fmt.Println("Could not reload zip codes, keeping previous data ", err)
+				return
+			}
+			fmt.Printf("Reloaded %d zip codes from %s in %v\n", stats.Rows, stats.Source, stats.LoadTime)
+		})
+	}
+
+	s := server{
+		zips:       reloader,
+		reloader:   reloader,
+		adminToken: *adminToken,
+		weatherClient: weather.Client{
+			Client:    upstream,
+			Precision: *precision,
+			Redirects: &weather.Redirects{},
+			Timeout:   *upstreamTimeout,
+			Retry:     retry,
+			Limiter:   weather.NewLimiter(*upstreamRate, *upstreamBurst, *upstreamInFlight),
+			Points:    points,
+			PointsTTL: *pointsTTL,
+		},
+	}
+
+	httpServer := &http.Server{Handler: s.routes(),
+		Addr: *addr}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-served:
+		fmt.Println("Could not serve ", err)
+		stop()
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+		defer cancel()
+
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			fmt.Println("Could not finish requests in flight ", err)
+		}
+	}
 
 }
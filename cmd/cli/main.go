@@ -2,61 +2,161 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"sketch-go-course/pkg/location"
 	"sketch-go-course/pkg/weather"
 	"sort"
-	"strings"
+	"time"
+	_ "time/tzdata"
 )
 
 func main() {
 
-	// 1. type in zip code at the command prompt
-	reader := bufio.NewReader(os.Stdin)
+	zipSource := flag.String("zips", "zip.csv", "ZIP code dataset: a CSV file, a "+location.SnapshotExt+" snapshot, which has US ZIP codes and coordinates only, or \""+location.BuiltinSource+"\"")
+	countryStr := flag.String("country", "US", "country of the postal code entered: US, CA or MX")
+	timeout := flag.Duration("timeout", 30*time.Second, "time allowed for the forecast lookup, 0 for no limit")
+	pointsCache := flag.String("points-cache", defaultPointsCache(), "file caching the forecast grid point of each location between runs, empty to disable")
+	pointsTTL := flag.Duration("points-ttl", weather.DefaultPointsTTL, "how long a cached grid point is trusted")
+	flag.Parse()
 
-	fmt.Printf("Enter zip code: ")
-	zipCodeStr, _ := reader.ReadString('\n')
+	country, countryErr := location.ParseCountry(*countryStr)
 
-	// 2. get the forecast using the entered zip code
-
-	//  - parse CSV
+	if countryErr != nil {
+		fmt.Println(countryErr)
+		os.Exit(2)
+	}
 
-	zipCodeMap, zipCodeErr := location.LoadZipCodeMap("zip.csv")
+	zips, zipCodeErr := location.Open(*zipSource)
 
 	if zipCodeErr != nil {
-		fmt.Println("Could not load zip.csv", zipCodeStr)
-		return
+		fmt.Println("Could not load zip codes ", zipCodeErr)
+		os.Exit(1)
 	}
 
-	coords, ok := zipCodeMap[strings.TrimSpace(zipCodeStr)]
+	weatherClient := weather.Client{
+		Client:    &http.Client{},
+		Precision: weather.DefaultPrecision,
+		Timeout:   *timeout,
+		Retry:     weather.DefaultRetryPolicy,
+		PointsTTL: *pointsTTL,
+	}
 
-	if !ok {
-		fmt.Println("Could not find zipcode ", zipCodeStr)
-		return
+	if *pointsCache != "" {
+		// without the cache every lookup just asks upstream for the grid point
+		if err := os.MkdirAll(filepath.Dir(*pointsCache), 0755); err != nil {
+			fmt.Println("Could not create points cache, continuing without it ", err)
+		} else if cache, err := weather.OpenFilePointsCache(*pointsCache, 0, time.Now()); err != nil {
+			fmt.Println("Could not open points cache, continuing without it ", err)
+		} else {
+			weatherClient.Points = cache
+		}
 	}
 
-	weatherClient := weather.Client{
-		Client: &http.Client{},
+	runErr := run(context.Background(), zips, country, weatherClient, os.Stdin, os.Stdout)
+
+	if cache, ok := weatherClient.Points.(*weather.FilePointsCache); ok {
+		if err := cache.Flush(); err != nil {
+			fmt.Println("Could not save points cache ", err)
+		}
+	}
+
+	if runErr != nil {
+		fmt.Println(runErr)
+		os.Exit(1)
+	}
+}
+
+// defaultPointsCache is the points cache file in the user's cache directory,
+// or empty if there is none.
+func defaultPointsCache() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "sketch-go-course", "points.json")
+}
+
+func run(ctx context.Context, zips location.Resolver, country location.Country, weatherClient weather.Client, in io.Reader, out io.Writer) error {
+
+	// 1. type in zip code at the command prompt
+	reader := bufio.NewReader(in)
+
+	searcher, searchable := zips.(location.Searcher)
+
+	if country == location.US && searchable && searcher.PlaceNames() > 0 {
+		fmt.Fprintf(out, "Enter zip code, City, ST or coordinates: ")
+	} else if country == location.US {
+		fmt.Fprintf(out, "Enter zip code or coordinates: ")
+	} else {
+		fmt.Fprintf(out, "Enter %s postal code or coordinates: ", country)
 	}
 
-	forecast, fetchErr := weatherClient.FetchForecast(coords)
+	zipCodeStr, _ := reader.ReadString('\n')
+
+	// 2. get the forecast using the entered zip code
+
+	var resolution location.Resolution
+	var resolveErr error
+
+	if country == location.US {
+		resolution, resolveErr = location.ResolveQuery(zips, zipCodeStr)
+	} else if c, parseErr := location.ParseLatLong(zipCodeStr); parseErr == nil {
+		resolution = location.ResolveCoordinate(zips, c)
+	} else if errors.Is(parseErr, location.ErrAmbiguousCoordinate) {
+		resolveErr = parseErr
+	} else {
+		resolution, resolveErr = location.ResolvePostalCode(zips, country, zipCodeStr)
+	}
+
+	if resolveErr != nil {
+		return fmt.Errorf("could not find location: %w", resolveErr)
+	}
+
+	forecast, fetchErr := weatherClient.FetchForecastContext(ctx, resolution.Coordinate)
 
 	if fetchErr != nil {
-		fmt.Println("Could not get forecast ", fetchErr)
-		return
+		return fmt.Errorf("could not get forecast: %w", fetchErr)
+	}
+
+	if resolution.FromCoordinate {
+		if place := resolution.Place; place.City != "" && place.State != "" {
+			fmt.Fprintf(out, "\nForecast for %s (near %s, %s %s)\n", resolution.Coordinate, place.City, place.State, place.Zip)
+		} else if resolution.Zip != "" {
+			fmt.Fprintf(out, "\nForecast for %s (near %s)\n", resolution.Coordinate, resolution.Zip)
+		} else {
+			fmt.Fprintln(out, "\nForecast for ", resolution.Coordinate)
+		}
+	} else if resolution.Approximate {
+		fmt.Fprintf(out, "\nForecast near %s (zip code not found, using the %sxx area)\n", resolution.Zip, resolution.Zip[:3])
+	} else if place := resolution.Place; place.City != "" && place.State != "" {
+		fmt.Fprintf(out, "\nForecast for %s, %s (%s)\n", place.City, place.State, place.Zip)
+	} else {
+		fmt.Fprintln(out, "\nForecast for ", resolution.Zip)
 	}
 
-	fmt.Println("\nForecast for ", zipCodeStr)
+	// without a time zone the days are grouped as upstream reported them
+	loc, zoneErr := location.LoadPlaceTimeZone(resolution.Place)
+
+	if zoneErr != nil {
+		loc = nil
+	}
 
-	summary := forecast.Summary()
+	summary := forecast.SummaryIn(loc)
 
 	sort.Slice(summary.Days, func(i, j int) bool {
 		return summary.Days[i].Day.Before(summary.Days[j].Day)
 	})
 
 	for _, day := range summary.Days {
-		fmt.Printf("%v\t\t%v\t%v\t%v\n", day.Day.Weekday().String(), day.Low, day.High, day.ShortForecast)
+		fmt.Fprintf(out, "%v\t\t%v\t%v\t%v\n", day.Day.Weekday().String(), day.Low, day.High, day.ShortForecast)
 	}
+
+	return nil
 }
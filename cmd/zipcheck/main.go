@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sketch-go-course/pkg/location"
+)
+
+type report struct {
+	Datasets []datasetResponse `json:"datasets"`
+	Diff     *diffResponse     `json:"diff,omitempty"`
+}
+
+type datasetResponse struct {
+	Name   string                     `json:"name"`
+	Rows   int                        `json:"rows"`
+	Valid  int                        `json:"valid"`
+	Counts map[location.IssueKind]int `json:"counts"`
+	Issues []issueResponse            `json:"issues"`
+}
+
+type issueResponse struct {
+	Kind    location.IssueKind `json:"kind"`
+	Line    int                `json:"line"`
+	Zip     string             `json:"zip,omitempty"`
+	Message string             `json:"message"`
+}
+
+type diffResponse struct {
+	From    string          `json:"from"`
+	To      string          `json:"to"`
+	Added   []placeResponse `json:"added"`
+	Removed []placeResponse `json:"removed"`
+	Moved   []moveResponse  `json:"moved"`
+}
+
+type placeResponse struct {
+	Country string  `json:"country"`
+	Zip     string  `json:"zip"`
+	Lat     float64 `json:"lat"`
+	Long    float64 `json:"long"`
+}
+
+type moveResponse struct {
+	Country  string  `json:"country"`
+	Zip      string  `json:"zip"`
+	FromLat  float64 `json:"fromLat"`
+	FromLong float64 `json:"fromLong"`
+	ToLat    float64 `json:"toLat"`
+	ToLong   float64 `json:"toLong"`
+	Miles    float64 `json:"miles"`
+}
+
+// zipcheck validates one ZIP code CSV file, or validates two and reports
+// what changed from the first to the second, as JSON on stdout. It exits
+// with status 1 if any dataset has issues.
+func main() {
+
+	minMiles := flag.Float64("min-move", 0, "only report ZIP codes that moved more than this many miles")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: zipcheck [-min-move miles] dataset.csv [newer.csv]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 || flag.NArg() > 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	clean, err := run(flag.Args(), *minMiles, os.Stdout)
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if !clean {
+		os.Exit(1)
+	}
+}
+
+// run writes the report for filenames to out and reports whether every
+// dataset was free of issues.
+func run(filenames []string, minMiles float64, out io.Writer) (bool, error) {
+
+	var r report
+	var datasets []map[location.PostalCode]location.Place
+	clean := true
+
+	for _, filename := range filenames {
+		places, validation, err := location.ValidateFile(filename)
+
+		if err != nil {
+			return false, fmt.Errorf("could not read %s: %w", filename, err)
+		}
+
+		datasets = append(datasets, places)
+		r.Datasets = append(r.Datasets, newDatasetResponse(validation))
+		clean = clean && len(validation.Issues) == 0
+	}
+
+	if len(datasets) == 2 {
+		diff := location.DiffPlaces(datasets[0], datasets[1], minMiles)
+		r.Diff = &diffResponse{
+			From:    filenames[0],
+			To:      filenames[1],
+			Added:   newPlaceResponses(diff.Added),
+			Removed: newPlaceResponses(diff.Removed),
+			Moved:   make([]moveResponse, 0, len(diff.Moved)),
+		}
+
+		for _, move := range diff.Moved {
+			r.Diff.Moved = append(r.Diff.Moved, moveResponse{
+				Country:  string(move.Key.Country),
+				Zip:      move.Key.Code,
+				FromLat:  move.From.Lat,
+				FromLong: move.From.Long,
+				ToLat:    move.To.Lat,
+				ToLong:   move.To.Long,
+				Miles:    move.Miles,
+			})
+		}
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+
+	return clean, encoder.Encode(r)
+}
+
+func newDatasetResponse(validation location.ValidationReport) datasetResponse {
+
+	response := datasetResponse{
+		Name:   validation.Name,
+		Rows:   validation.Rows,
+		Valid:  validation.Valid,
+		Counts: validation.Counts(),
+		Issues: make([]issueResponse, 0, len(validation.Issues)),
+	}
+
+	for _, issue := range validation.Issues {
+		response.Issues = append(response.Issues, issueResponse{
+			Kind:    issue.Kind,
+			Line:    issue.Line,
+			Zip:     issue.Zip,
+			Message: issue.Err.Error(),
+		})
+	}
+
+	return response
+}
+
+func newPlaceResponses(places []location.Place) []placeResponse {
+
+	response := make([]placeResponse, 0, len(places))
+
+	for _, place := range places {
+		response = append(response, placeResponse{
+			Country: string(place.Country),
+			Zip:     place.Zip,
+			Lat:     place.Coordinate.Lat,
+			Long:    place.Coordinate.Long,
+		})
+	}
+
+	return response
+}
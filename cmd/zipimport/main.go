@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sketch-go-course/pkg/location"
+)
+
+// zipimport converts a Census Bureau ZCTA gazetteer file into a ZIP code CSV
+// file for the api and cli commands, optionally adding city, state, county
+// and time zone columns from a second CSV file keyed by ZIP code.
+func main() {
+
+	gazetteer := flag.String("gazetteer", "", "ZCTA gazetteer file to read, e.g. 2020_Gaz_zcta_national.txt")
+	names := flag.String("names", "", "optional CSV file with zip and city, state, county or time zone columns")
+	out := flag.String("out", "zip.csv", "ZIP code CSV file to write")
+	flag.Parse()
+
+	if *gazetteer == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	places, report, err := location.LoadGazetteer(*gazetteer, location.Lenient)
+
+	if err != nil {
+		fmt.Println("Could not load", *gazetteer, err)
+		os.Exit(1)
+	}
+
+	for _, skipped := range report.Skipped {
+		fmt.Println("skipped", skipped)
+	}
+
+	if *names != "" {
+		nameMap, nameReport, err := location.LoadPlaceNames(*names)
+
+		if err != nil {
+			fmt.Println("Could not load", *names, err)
+			os.Exit(1)
+		}
+
+		for _, skipped := range nameReport.Skipped {
+			fmt.Println("skipped", skipped)
+		}
+
+		matched := location.MergePlaceNames(places, nameMap)
+		fmt.Printf("named %d of %d zip codes from %s\n", matched, len(places), *names)
+	}
+
+	f, err := os.Create(*out)
+
+	if err != nil {
+		fmt.Println("Could not create", *out, err)
+		os.Exit(1)
+	}
+
+	if err := location.WritePlaces(f, places); err != nil {
+		_ = f.Close()
+		fmt.Println("Could not write", *out, err)
+		os.Exit(1)
+	}
+
+	if err := f.Close(); err != nil {
+		fmt.Println("Could not write", *out, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("wrote %d zip codes to %s\n", len(places), *out)
+}
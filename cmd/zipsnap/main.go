@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sketch-go-course/pkg/location"
+)
+
+// zipsnap converts a ZIP code CSV file into a binary snapshot that the api
+// and cli commands can load with -zips.
+func main() {
+
+	in := flag.String("in", "zip.csv", "ZIP code CSV file to read")
+	out := flag.String("out", "zip"+location.SnapshotExt, "snapshot file to write")
+	flag.Parse()
+
+	zipCodeMap, err := location.LoadZipCodeMap(*in)
+
+	if err != nil {
+		fmt.Println("Could not load", *in, err)
+		os.Exit(1)
+	}
+
+	f, err := os.Create(*out)
+
+	if err != nil {
+		fmt.Println("Could not create", *out, err)
+		os.Exit(1)
+	}
+
+	if err := location.WriteSnapshot(f, zipCodeMap); err != nil {
+		_ = f.Close()
+		fmt.Println("Could not write", *out, err)
+		os.Exit(1)
+	}
+
+	if err := f.Close(); err != nil {
+		fmt.Println("Could not write", *out, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("wrote %d zip codes to %s\n", len(zipCodeMap), *out)
+}
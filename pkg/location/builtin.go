@@ -0,0 +1,27 @@
+package location
+
+import (
+	"sketch-go-course/pkg/location/zipdata"
+	"strings"
+	"sync"
+)
+
+var builtin struct {
+	once  sync.Once
+	index *Index
+	err   error
+}
+
+// Builtin returns an index over the ZIP code dataset compiled into the
+// binary. The dataset is parsed on first use.
+func Builtin() (*Index, error) {
+	builtin.once.Do(func() {
+		zipCodeMap, _, err := ReadZipCodes(strings.NewReader(zipdata.CSV), BuiltinSource, Strict)
+		if err != nil {
+			builtin.err = err
+			return
+		}
+		builtin.index = NewIndex(zipCodeMap)
+	})
+	return builtin.index, builtin.err
+}
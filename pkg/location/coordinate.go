@@ -0,0 +1,80 @@
+package location
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidLatitude  = errors.New("latitude must be between -90 and 90")
+	ErrInvalidLongitude = errors.New("longitude must be between -180 and 180")
+)
+
+// Coordinate is a validated latitude/longitude pair in decimal degrees.
+type Coordinate struct {
+	Lat  float64
+	Long float64
+}
+
+// NewCoordinate returns the coordinate for lat and long, or an error if
+// either is out of range.
+func NewCoordinate(lat, long float64) (Coordinate, error) {
+	c := Coordinate{Lat: lat, Long: long}
+	if err := c.Validate(); err != nil {
+		return Coordinate{}, err
+	}
+	return c, nil
+}
+
+// ParseCoordinate parses decimal degree strings such as "38.676026" and
+// "-90.377994" into a validated coordinate.
+func ParseCoordinate(lat, long string) (Coordinate, error) {
+	latValue, err := parseDegrees(lat)
+	if err != nil {
+		return Coordinate{}, fmt.Errorf("latitude %q: %w", lat, err)
+	}
+
+	longValue, err := parseDegrees(long)
+	if err != nil {
+		return Coordinate{}, fmt.Errorf("longitude %q: %w", long, err)
+	}
+
+	return NewCoordinate(latValue, longValue)
+}
+
+func parseDegrees(s string) (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, errors.Unwrap(err)
+	}
+	return v, nil
+}
+
+// Validate reports whether c lies within the valid latitude and longitude
+// ranges.
+func (c Coordinate) Validate() error {
+	if math.IsNaN(c.Lat) || c.Lat < -90 || c.Lat > 90 {
+		return fmt.Errorf("%v: %w", c.Lat, ErrInvalidLatitude)
+	}
+	if math.IsNaN(c.Long) || c.Long < -180 || c.Long > 180 {
+		return fmt.Errorf("%v: %w", c.Long, ErrInvalidLongitude)
+	}
+	return nil
+}
+
+// String returns the "lat,long" form used on the wire, e.g.
+// "38.676026,-90.377994".
+func (c Coordinate) String() string {
+	return FormatDegrees(c.Lat) + "," + FormatDegrees(c.Long)
+}
+
+// FormatDegrees formats v in its shortest decimal form without an exponent.
+func FormatDegrees(v float64) string {
+	if v == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,38 @@
+package location
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseCoordinate(t *testing.T) {
+
+	c, err := ParseCoordinate(" 38.676026", "-90.377994 ")
+
+	require.NoError(t, err)
+	assert.Equal(t, Coordinate{Lat: 38.676026, Long: -90.377994}, c)
+	assert.Equal(t, "38.676026,-90.377994", c.String())
+}
+
+func TestParseCoordinateInvalid(t *testing.T) {
+
+	_, err := ParseCoordinate("abc", "-90.377994")
+	assert.Error(t, err)
+
+	_, err = ParseCoordinate("91", "-90.377994")
+	assert.True(t, errors.Is(err, ErrInvalidLatitude))
+
+	_, err = ParseCoordinate("38.676026", "-180.5")
+	assert.True(t, errors.Is(err, ErrInvalidLongitude))
+
+	_, err = ParseCoordinate("NaN", "0")
+	assert.True(t, errors.Is(err, ErrInvalidLatitude))
+}
+
+func TestCoordinateString(t *testing.T) {
+
+	assert.Equal(t, "18.180555,-66.749961", Coordinate{Lat: 18.180555, Long: -66.749961}.String())
+	assert.Equal(t, "40,0", Coordinate{Lat: 40.000, Long: -0.0}.String())
+}
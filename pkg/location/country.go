@@ -0,0 +1,126 @@
+package location
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrUnknownCountry    = errors.New("unsupported country")
+	ErrInvalidPostalCode = errors.New("invalid postal code")
+)
+
+// Country is an ISO 3166-1 alpha-2 country code.
+type Country string
+
+const (
+	US Country = "US"
+	CA Country = "CA"
+	MX Country = "MX"
+)
+
+// PostalCode identifies a postal area within a country. Code is in the
+// canonical form for its country, see NormalizePostalCode.
+type PostalCode struct {
+	Country Country
+	Code    string
+}
+
+func (p PostalCode) String() string {
+	return string(p.Country) + " " + p.Code
+}
+
+// countryRules holds the postal code normalization for each supported
+// country. Each function returns the canonical key the datasets use.
+var countryRules = map[Country]func(string) (string, error){
+	US: NormalizeZip,
+	CA: normalizeCanadianFSA,
+	MX: normalizeMexicanCode,
+}
+
+var countryNames = map[string]Country{
+	"US": US, "USA": US, "UNITED STATES": US,
+	"CA": CA, "CAN": CA, "CANADA": CA,
+	"MX": MX, "MEX": MX, "MEXICO": MX, "MÉXICO": MX,
+}
+
+// ParseCountry accepts a supported country as an alpha-2 or alpha-3 code or
+// its English name, in any case.
+func ParseCountry(s string) (Country, error) {
+	country, ok := countryNames[strings.ToUpper(strings.TrimSpace(s))]
+	if !ok {
+		return "", fmt.Errorf("%q: %w", s, ErrUnknownCountry)
+	}
+	return country, nil
+}
+
+// NormalizePostalCode returns the canonical form of a postal code in
+// country:
+//
+//	US: five digit ZIP code, see NormalizeZip
+//	CA: three character forward sortation area, "k1a 0b1" -> "K1A"
+//	MX: five digit código postal, "C.P. 6700" -> "06700"
+func NormalizePostalCode(country Country, s string) (string, error) {
+	normalize, ok := countryRules[country]
+	if !ok {
+		return "", fmt.Errorf("%q: %w", country, ErrUnknownCountry)
+	}
+	return normalize(s)
+}
+
+// Letters Canada Post never uses in postal codes, and the further letters
+// it never uses to start one.
+const (
+	canadianExcludedLetters      = "DFIOQU"
+	canadianExcludedFirstLetters = "WZ"
+)
+
+// normalizeCanadianFSA accepts a full postal code like "K1A 0B1" or just
+// its forward sortation area "K1A", and returns the FSA.
+func normalizeCanadianFSA(s string) (string, error) {
+	code := strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(s)))
+
+	if len(code) != 3 && len(code) != 6 {
+		return "", fmt.Errorf("%q: %w", s, ErrInvalidPostalCode)
+	}
+
+	for i := 0; i < len(code); i++ {
+		c := code[i]
+		if i%2 == 1 {
+			if c < '0' || c > '9' {
+				return "", fmt.Errorf("%q: %w", s, ErrInvalidPostalCode)
+			}
+			continue
+		}
+		if c < 'A' || c > 'Z' || strings.IndexByte(canadianExcludedLetters, c) >= 0 ||
+			(i == 0 && strings.IndexByte(canadianExcludedFirstLetters, c) >= 0) {
+			return "", fmt.Errorf("%q: %w", s, ErrInvalidPostalCode)
+		}
+	}
+
+	return code[:3], nil
+}
+
+// normalizeMexicanCode accepts a five digit código postal, optionally after
+// "C.P." and with its leading zero lost, e.g. "6700" for Mexico City.
+func normalizeMexicanCode(s string) (string, error) {
+	code := strings.ToUpper(strings.TrimSpace(s))
+	for _, prefix := range []string{"C.P.", "CP"} {
+		code = strings.TrimSpace(strings.TrimPrefix(code, prefix))
+	}
+
+	if !isDigits(code) || len(code) < 4 || len(code) > 5 {
+		return "", fmt.Errorf("%q: %w", s, ErrInvalidPostalCode)
+	}
+
+	code = strings.Repeat("0", 5-len(code)) + code
+
+	// the first two digits name the state, 01 to 16 for Mexico City through
+	// 99 for Zacatecas; none start with 00
+	if code[:2] == "00" {
+		return "", fmt.Errorf("%q: %w", s, ErrInvalidPostalCode)
+	}
+
+	return code, nil
+}
@@ -0,0 +1,124 @@
+package location
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestParseCountry(t *testing.T) {
+
+	for input, expected := range map[string]Country{"us": US, "USA": US, " Canada ": CA, "can": CA, "MX": MX, "México": MX} {
+		country, err := ParseCountry(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, country, input)
+	}
+
+	_, err := ParseCountry("FR")
+	assert.True(t, errors.Is(err, ErrUnknownCountry))
+}
+
+func TestNormalizePostalCode(t *testing.T) {
+
+	valid := []struct {
+		country  Country
+		input    string
+		expected string
+	}{
+		{US, "601-1234", "00601"},
+		{CA, "K1A 0B1", "K1A"},
+		{CA, "k1a0b1", "K1A"},
+		{CA, " m5v ", "M5V"},
+		{CA, "T2P-1J9", "T2P"},
+		{MX, "06700", "06700"},
+		{MX, "6700", "06700"},
+		{MX, "C.P. 44100", "44100"},
+	}
+
+	for _, tc := range valid {
+		code, err := NormalizePostalCode(tc.country, tc.input)
+		assert.NoError(t, err, tc.input)
+		assert.Equal(t, tc.expected, code, tc.input)
+	}
+
+	invalid := []struct {
+		country Country
+		input   string
+	}{
+		{CA, "K1A 0B"},
+		{CA, "D1A 0B1"},
+		{CA, "W1A"},
+		{CA, "K1O"},
+		{CA, "KKA"},
+		{MX, "123"},
+		{MX, "00700"},
+		{MX, "4410A"},
+	}
+
+	for _, tc := range invalid {
+		_, err := NormalizePostalCode(tc.country, tc.input)
+		assert.True(t, errors.Is(err, ErrInvalidPostalCode), tc.input)
+	}
+
+	_, err := NormalizePostalCode("FR", "75001")
+	assert.True(t, errors.Is(err, ErrUnknownCountry))
+}
+
+func TestInternationalDataset(t *testing.T) {
+
+	idx, err := LoadZipCodeIndex("testdata/international.csv")
+	require.NoError(t, err)
+	assert.Equal(t, 7, idx.Len())
+
+	res, err := ResolvePostalCode(idx, CA, "K1A 0B1")
+	require.NoError(t, err)
+	assert.Equal(t, "K1A", res.Zip)
+	assert.Equal(t, "Ottawa", res.Place.City)
+	assert.Equal(t, CA, res.Place.Country)
+
+	// the same code in two countries are different places
+	mx, err := ResolvePostalCode(idx, MX, "63132")
+	require.NoError(t, err)
+	us, err := ResolvePostalCode(idx, US, "63132")
+	require.NoError(t, err)
+	assert.Equal(t, "Tepic", mx.Place.City)
+	assert.Equal(t, "Saint Louis", us.Place.City)
+
+	c, err := idx.Lookup("00601")
+	require.NoError(t, err)
+	assert.Equal(t, 18.180555, c.Lat)
+
+	_, err = ResolvePostalCode(idx, MX, "06701")
+	assert.True(t, errors.Is(err, ErrZipNotFound))
+
+	_, err = ResolvePostalCode(MapResolver{}, CA, "K1A")
+	assert.True(t, errors.Is(err, ErrUnknownCountry))
+
+	matches := idx.Nearest(Coordinate{Lat: 19.4, Long: -99.1}, 1)
+	require.Len(t, matches, 1)
+	assert.Equal(t, MX, matches[0].Country)
+	assert.Equal(t, "06700", matches[0].Zip)
+
+	suggestions := idx.Suggest("ciudad de mexico", 5)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, MX, suggestions[0].Country)
+
+	// only US rows have a bundled time zone
+	_, err = PlaceTimeZone(mx.Place)
+	assert.True(t, errors.Is(err, ErrUnknownTimeZone))
+
+	zipCodeMap, err := LoadZipCodeMap("testdata/international.csv")
+	require.NoError(t, err)
+	assert.Len(t, zipCodeMap, 2)
+}
+
+func TestInternationalDatasetRejectsBadCodes(t *testing.T) {
+
+	_, _, err := ReadPlaces(strings.NewReader("zip,country,lat,lng\nK1A,US,45,-75\n"), "input", Strict)
+	assert.True(t, errors.Is(err, ErrInvalidZip))
+
+	_, _, err = ReadPlaces(strings.NewReader("zip,country,lat,lng\nK1A,FR,45,-75\n"), "input", Strict)
+	assert.True(t, errors.Is(err, ErrUnknownCountry))
+}
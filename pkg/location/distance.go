@@ -0,0 +1,121 @@
+package location
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidBoundingBox = errors.New("invalid bounding box")
+
+// Distance returns the great-circle distance in miles between a and b.
+func Distance(a, b Coordinate) float64 {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dLat := lat2 - lat1
+	dLong := radians(b.Long - a.Long)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLong/2)*math.Sin(dLong/2)
+
+	return 2 * math.Asin(math.Min(math.Sqrt(h), 1)) * earthRadiusMiles
+}
+
+// Bearing returns the initial compass bearing in degrees, from 0 up to but
+// not including 360, for travelling along a great circle from a to b.
+func Bearing(a, b Coordinate) float64 {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dLong := radians(b.Long - a.Long)
+
+	y := math.Sin(dLong) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLong)
+
+	bearing := math.Mod(degrees(math.Atan2(y, x))+360, 360)
+	if bearing >= 360 {
+		bearing = 0
+	}
+	return bearing
+}
+
+// BoundingBox is a latitude/longitude rectangle. When West is greater than
+// East the box crosses the antimeridian.
+type BoundingBox struct {
+	South float64
+	West  float64
+	North float64
+	East  float64
+}
+
+// ParseBoundingBox parses a box written west,south,east,north, the order
+// GeoJSON uses for bbox members.
+func ParseBoundingBox(s string) (BoundingBox, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return BoundingBox{}, fmt.Errorf("%q: want west,south,east,north: %w", s, ErrInvalidBoundingBox)
+	}
+
+	var edges [4]float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return BoundingBox{}, fmt.Errorf("%q: %w", s, ErrInvalidBoundingBox)
+		}
+		edges[i] = v
+	}
+
+	box := BoundingBox{West: edges[0], South: edges[1], East: edges[2], North: edges[3]}
+	if err := box.Validate(); err != nil {
+		return BoundingBox{}, fmt.Errorf("%q: %w", s, err)
+	}
+	return box, nil
+}
+
+// Validate reports whether the box edges are valid coordinates and South is
+// not above North.
+func (b BoundingBox) Validate() error {
+	if err := (Coordinate{Lat: b.South, Long: b.West}).Validate(); err != nil {
+		return err
+	}
+	if err := (Coordinate{Lat: b.North, Long: b.East}).Validate(); err != nil {
+		return err
+	}
+	if b.South > b.North {
+		return fmt.Errorf("south %v is above north %v: %w", b.South, b.North, ErrInvalidLatitude)
+	}
+	return nil
+}
+
+// Contains reports whether c lies inside the box, edges included.
+func (b BoundingBox) Contains(c Coordinate) bool {
+	if c.Lat < b.South || c.Lat > b.North {
+		return false
+	}
+	if b.West <= b.East {
+		return c.Long >= b.West && c.Long <= b.East
+	}
+	return c.Long >= b.West || c.Long <= b.East
+}
+
+// Center returns the midpoint of the box in latitude/longitude space.
+func (b BoundingBox) Center() Coordinate {
+	east := b.East
+	if b.West > east {
+		east += 360
+	}
+
+	long := (b.West + east) / 2
+	if long > 180 {
+		long -= 360
+	}
+
+	return Coordinate{Lat: (b.South + b.North) / 2, Long: long}
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
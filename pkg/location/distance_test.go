@@ -0,0 +1,162 @@
+package location
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+var (
+	stLouis      = Coordinate{Lat: 38.6270, Long: -90.1994}
+	kansasCity   = Coordinate{Lat: 39.0997, Long: -94.5786}
+	northPole    = Coordinate{Lat: 90, Long: 0}
+	dateLineWest = Coordinate{Lat: 0, Long: 179.5}
+	dateLineEast = Coordinate{Lat: 0, Long: -179.5}
+)
+
+func TestDistance(t *testing.T) {
+
+	assert.InDelta(t, 238, Distance(stLouis, kansasCity), 2)
+	assert.InDelta(t, 0, Distance(stLouis, stLouis), 1e-9)
+	assert.InDelta(t, 69.1, Distance(dateLineWest, dateLineEast), 0.5)
+}
+
+func TestBearing(t *testing.T) {
+
+	assert.InDelta(t, 0, Bearing(stLouis, northPole), 1e-9)
+	assert.InDelta(t, 90, Bearing(dateLineWest, dateLineEast), 1e-6)
+	assert.InDelta(t, 270, Bearing(dateLineEast, dateLineWest), 1e-6)
+	assert.InDelta(t, 280, Bearing(stLouis, kansasCity), 2)
+}
+
+func TestBoundingBoxContains(t *testing.T) {
+
+	box := BoundingBox{South: 38, West: -91, North: 39, East: -90}
+	assert.True(t, box.Contains(stLouis))
+	assert.False(t, box.Contains(kansasCity))
+	assert.Equal(t, Coordinate{Lat: 38.5, Long: -90.5}, box.Center())
+
+	wrapped := BoundingBox{South: -1, West: 179, North: 1, East: -179}
+	assert.True(t, wrapped.Contains(dateLineWest))
+	assert.True(t, wrapped.Contains(dateLineEast))
+	assert.False(t, wrapped.Contains(Coordinate{Lat: 0, Long: 0}))
+	assert.Equal(t, Coordinate{Lat: 0, Long: 180}, wrapped.Center())
+
+	assert.Error(t, BoundingBox{South: 10, West: 0, North: 5, East: 1}.Validate())
+}
+
+func TestIndexWithin(t *testing.T) {
+
+	idx, err := LoadZipCodeIndex("zipdata/zip.csv")
+	require.NoError(t, err)
+
+	matches, err := idx.WithinZip("00601", 10)
+	require.NoError(t, err)
+
+	require.NotEmpty(t, matches)
+	assert.Equal(t, "00601", matches[0].Zip)
+	for i, m := range matches {
+		assert.True(t, m.Distance <= 10)
+		if i > 0 {
+			assert.True(t, matches[i-1].Distance <= m.Distance)
+		}
+	}
+
+	// every ZIP the index skipped must really be out of range
+	center, _ := idx.Lookup("00601")
+	count := 0
+	for _, p := range idx.points {
+		if Distance(center, p.coordinate) <= 10 {
+			count++
+		}
+	}
+	assert.Len(t, matches, count)
+
+	_, err = idx.WithinZip("99999", 10)
+	assert.Error(t, err)
+}
+
+func TestIndexInBox(t *testing.T) {
+
+	idx, err := LoadZipCodeIndex("zipdata/zip.csv")
+	require.NoError(t, err)
+
+	box := BoundingBox{South: 38.5, West: -90.5, North: 38.8, East: -90.2}
+	matches := idx.InBox(box)
+
+	require.NotEmpty(t, matches)
+	for i, m := range matches {
+		assert.True(t, box.Contains(m.Coordinate))
+		if i > 0 {
+			assert.True(t, matches[i-1].Distance <= m.Distance)
+		}
+	}
+}
+
+func TestIndexInBoxMatchesScan(t *testing.T) {
+
+	// points all over the globe, poles and antimeridian included
+	random := rand.New(rand.NewSource(1))
+	zipCodeMap := make(map[string]Coordinate)
+	for i := 0; i < 5000; i++ {
+		zipCodeMap[fmt.Sprintf("%05d", i)] = Coordinate{Lat: random.Float64()*180 - 90, Long: random.Float64()*360 - 180}
+	}
+	zipCodeMap["90000"] = Coordinate{Lat: 90, Long: 0}
+	zipCodeMap["90001"] = Coordinate{Lat: 0, Long: 180}
+	idx := NewIndex(zipCodeMap)
+
+	boxes := []BoundingBox{
+		{South: -90, West: -180, North: 90, East: 180},
+		{South: 10, West: 170, North: 60, East: -170},
+		{South: -10, West: 179, North: 10, East: 180},
+		{South: 80, West: -180, North: 90, East: 180},
+		{South: -85, West: 95, North: -20, East: 85},
+	}
+	for i := 0; i < 200; i++ {
+		south, north := random.Float64()*180-90, random.Float64()*180-90
+		if south > north {
+			south, north = north, south
+		}
+		boxes = append(boxes, BoundingBox{South: south, West: random.Float64()*360 - 180, North: north, East: random.Float64()*360 - 180})
+	}
+
+	for _, box := range boxes {
+		var want []string
+		for zip, c := range zipCodeMap {
+			if box.Contains(c) {
+				want = append(want, zip)
+			}
+		}
+
+		var got []string
+		for _, m := range idx.InBox(box) {
+			got = append(got, m.Zip)
+		}
+
+		sort.Strings(want)
+		sort.Strings(got)
+		assert.Equal(t, want, got, "%+v", box)
+	}
+}
+
+func TestParseBoundingBox(t *testing.T) {
+
+	box, err := ParseBoundingBox("-91, 38, -90, 39")
+	require.NoError(t, err)
+	assert.Equal(t, BoundingBox{South: 38, West: -91, North: 39, East: -90}, box)
+
+	box, err = ParseBoundingBox("179,-1,-179,1")
+	require.NoError(t, err)
+	assert.True(t, box.Contains(dateLineEast))
+
+	_, err = ParseBoundingBox("-91,38,-90")
+	assert.True(t, errors.Is(err, ErrInvalidBoundingBox))
+	_, err = ParseBoundingBox("-91,39,-90,38")
+	assert.True(t, errors.Is(err, ErrInvalidLatitude))
+	_, err = ParseBoundingBox("a,b,c,d")
+	assert.True(t, errors.Is(err, ErrInvalidBoundingBox))
+}
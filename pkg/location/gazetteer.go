@@ -0,0 +1,221 @@
+package location
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ReadGazetteer reads a Census Bureau ZCTA gazetteer file: tab separated,
+// with the ZCTA in GEOID and its internal point in INTPTLAT and INTPTLONG.
+// ZCTAs are returned as US ZIP codes. See ReadPlaces for mode.
+func ReadGazetteer(r io.Reader, name string, mode Mode) (map[PostalCode]Place, LoadReport, error) {
+	decoder, err := newDecoder(r, name, '\t')
+	if err != nil {
+		return nil, LoadReport{}, err
+	}
+	return readPlaces(decoder, name, mode)
+}
+
+// LoadGazetteer reads a Census Bureau ZCTA gazetteer file. See ReadGazetteer.
+func LoadGazetteer(filename string, mode Mode) (map[PostalCode]Place, LoadReport, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, LoadReport{}, err
+	}
+	defer f.Close()
+
+	return ReadGazetteer(f, filename, mode)
+}
+
+// ReadPlaceNames reads a CSV stream with a ZIP code column and any of the
+// city, state, county and time zone columns a dataset may have. Coordinates
+// are not needed and are ignored. Rows with a malformed ZIP code are skipped
+// and listed in the report.
+func ReadPlaceNames(r io.Reader, name string) (map[PostalCode]Place, LoadReport, error) {
+	var report LoadReport
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, report, fmt.Errorf("%s: no header row", name)
+	}
+	if err != nil {
+		return nil, report, fmt.Errorf("%s: reading header: %w", name, err)
+	}
+
+	zipColumn := findColumn(header, zipColumnNames)
+	if zipColumn < 0 {
+		return nil, report, fmt.Errorf("%s: no %s column in header %q: %w", name, zipColumnNames[0], header, ErrMissingColumn)
+	}
+
+	cityColumn := findColumn(header, cityColumnNames)
+	stateColumn := findColumn(header, stateColumnNames)
+	countyColumn := findColumn(header, countyColumnNames)
+	timeZoneColumn := findColumn(header, timeZoneColumnNames)
+
+	names := make(map[PostalCode]Place)
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, report, fmt.Errorf("%s: %w", name, err)
+			}
+			report.Rows++
+			report.Skipped = append(report.Skipped, &RowError{Name: name, Line: parseErr.Line, Err: parseErr.Err})
+			continue
+		}
+
+		report.Rows++
+
+		raw := optionalField(record, zipColumn)
+		zip, err := NormalizeZip(raw)
+		if err != nil {
+			report.Skipped = append(report.Skipped, &RowError{Name: name, Line: line, Zip: raw, Err: err})
+			continue
+		}
+
+		place := Place{
+			Country:    US,
+			Zip:        zip,
+			City:       optionalField(record, cityColumn),
+			State:      strings.ToUpper(optionalField(record, stateColumn)),
+			CountyFIPS: optionalField(record, countyColumn),
+			TimeZone:   optionalField(record, timeZoneColumn),
+		}
+
+		if len(place.CountyFIPS) == 4 && isDigits(place.CountyFIPS) {
+			place.CountyFIPS = "0" + place.CountyFIPS
+		}
+
+		names[place.PostalCode()] = place
+	}
+
+	return names, report, nil
+}
+
+// LoadPlaceNames reads a CSV file of place names. See ReadPlaceNames.
+func LoadPlaceNames(filename string) (map[PostalCode]Place, LoadReport, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, LoadReport{}, err
+	}
+	defer f.Close()
+
+	return ReadPlaceNames(f, filename)
+}
+
+// MergePlaceNames copies the city, state, county and time zone of each entry
+// in names onto the place with the same postal code, leaving fields names
+// does not have alone. It returns how many places were matched.
+func MergePlaceNames(places map[PostalCode]Place, names map[PostalCode]Place) int {
+	matched := 0
+
+	for key, place := range places {
+		n, ok := names[key]
+		if !ok {
+			continue
+		}
+		matched++
+
+		if n.City != "" {
+			place.City = n.City
+		}
+		if n.State != "" {
+			place.State = n.State
+		}
+		if n.CountyFIPS != "" {
+			place.CountyFIPS = n.CountyFIPS
+		}
+		if n.TimeZone != "" {
+			place.TimeZone = n.TimeZone
+		}
+
+		places[key] = place
+	}
+
+	return matched
+}
+
+// WritePlaces writes places as a CSV dataset ReadPlaces can load, ordered by
+// country and postal code. Optional columns are only written when some
+// place has a value for them, and the country column only when some place
+// is outside the US.
+func WritePlaces(w io.Writer, places map[PostalCode]Place) error {
+	sorted := make([]Place, 0, len(places))
+	for _, p := range places {
+		sorted = append(sorted, p)
+	}
+	sortPlaces(sorted)
+
+	columns := []struct {
+		name  string
+		value func(Place) string
+	}{
+		{"country", func(p Place) string { return string(p.Country) }},
+		{"zip", func(p Place) string { return p.Zip }},
+		{"lat", func(p Place) string { return FormatDegrees(p.Coordinate.Lat) }},
+		{"lng", func(p Place) string { return FormatDegrees(p.Coordinate.Long) }},
+		{"city", func(p Place) string { return p.City }},
+		{"state_id", func(p Place) string { return p.State }},
+		{"county_fips", func(p Place) string { return p.CountyFIPS }},
+		{"timezone", func(p Place) string { return p.TimeZone }},
+	}
+
+	used := make([]bool, len(columns))
+	for i, column := range columns {
+		switch column.name {
+		case "zip", "lat", "lng":
+			used[i] = true
+			continue
+		}
+
+		for _, p := range sorted {
+			if v := column.value(p); v != "" && (column.name != "country" || p.Country != US) {
+				used[i] = true
+				break
+			}
+		}
+	}
+
+	writer := csv.NewWriter(w)
+
+	var record []string
+	for i, column := range columns {
+		if used[i] {
+			record = append(record, column.name)
+		}
+	}
+	if err := writer.Write(record); err != nil {
+		return err
+	}
+
+	for _, p := range sorted {
+		record = record[:0]
+		for i, column := range columns {
+			if used[i] {
+				record = append(record, column.value(p))
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,71 @@
+package location
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestLoadGazetteer(t *testing.T) {
+
+	places, report, err := LoadGazetteer("testdata/gazetteer.txt", Lenient)
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, report.Rows)
+	require.Len(t, report.Skipped, 1)
+	assert.Equal(t, 5, report.Skipped[0].Line)
+
+	require.Len(t, places, 3)
+	place := places[PostalCode{Country: US, Code: "63132"}]
+	assert.Equal(t, Coordinate{Lat: 38.676026, Long: -90.377994}, place.Coordinate)
+
+	_, _, err = LoadGazetteer("testdata/gazetteer.txt", Strict)
+	var rowErr *RowError
+	assert.True(t, errors.As(err, &rowErr))
+}
+
+func TestMergePlaceNames(t *testing.T) {
+
+	places, _, err := LoadGazetteer("testdata/gazetteer.txt", Lenient)
+	require.NoError(t, err)
+
+	names, report, err := LoadPlaceNames("testdata/names.csv")
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.Rows)
+	assert.Len(t, report.Skipped, 1)
+
+	assert.Equal(t, 2, MergePlaceNames(places, names))
+
+	place := places[PostalCode{Country: US, Code: "63132"}]
+	assert.Equal(t, "Saint Louis", place.City)
+	assert.Equal(t, "MO", place.State)
+	assert.Equal(t, "29189", place.CountyFIPS)
+	assert.Equal(t, Coordinate{Lat: 38.676026, Long: -90.377994}, place.Coordinate)
+
+	assert.Empty(t, places[PostalCode{Country: US, Code: "00602"}].City)
+	assert.NotContains(t, places, PostalCode{Country: US, Code: "99999"})
+}
+
+func TestWritePlaces(t *testing.T) {
+
+	places, _, err := LoadGazetteer("testdata/gazetteer.txt", Lenient)
+	require.NoError(t, err)
+	names, _, err := LoadPlaceNames("testdata/names.csv")
+	require.NoError(t, err)
+	MergePlaceNames(places, names)
+
+	var b bytes.Buffer
+	require.NoError(t, WritePlaces(&b, places))
+
+	assert.Equal(t, "zip,lat,lng,city,state_id,county_fips\n"+
+		"00601,18.180555,-66.749961,Adjuntas,PR,72001\n"+
+		"00602,18.361945,-67.175597,,,\n"+
+		"63132,38.676026,-90.377994,Saint Louis,MO,29189\n", b.String())
+
+	// what we write loads back unchanged
+	loaded, _, err := ReadPlaces(&b, "written.csv", Strict)
+	require.NoError(t, err)
+	assert.Equal(t, places, loaded)
+}
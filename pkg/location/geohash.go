@@ -0,0 +1,206 @@
+package location
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var ErrInvalidGeohash = errors.New("invalid geohash")
+
+// MaxGeohashPrecision is the longest geohash EncodeGeohash produces. Twelve
+// characters pin a point to a few centimetres, well past float64 degrees'
+// useful resolution for ZIP centroids.
+const MaxGeohashPrecision = 12
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// EncodeGeohash returns the geohash of c with precision characters, from 1
+// to MaxGeohashPrecision.
+func EncodeGeohash(c Coordinate, precision int) (string, error) {
+	if precision < 1 || precision > MaxGeohashPrecision {
+		return "", fmt.Errorf("precision %d must be from 1 to %d: %w", precision, MaxGeohashPrecision, ErrInvalidGeohash)
+	}
+	if err := c.Validate(); err != nil {
+		return "", err
+	}
+
+	lat := [2]float64{-90, 90}
+	long := [2]float64{-180, 180}
+
+	var b strings.Builder
+	even := true
+	ch, bit := 0, 0
+
+	// bits alternate longitude, latitude, starting with longitude, and each
+	// halves the range it refines
+	for b.Len() < precision {
+		r, v := &lat, c.Lat
+		if even {
+			r, v = &long, c.Long
+		}
+
+		mid := (r[0] + r[1]) / 2
+		ch <<= 1
+		if v >= mid {
+			ch |= 1
+			r[0] = mid
+		} else {
+			r[1] = mid
+		}
+
+		even = !even
+		if bit++; bit == 5 {
+			b.WriteByte(geohashAlphabet[ch])
+			ch, bit = 0, 0
+		}
+	}
+
+	return b.String(), nil
+}
+
+// GeohashBounds returns the cell hash covers. Geohashes are case
+// insensitive.
+func GeohashBounds(hash string) (BoundingBox, error) {
+	if hash == "" || len(hash) > MaxGeohashPrecision {
+		return BoundingBox{}, fmt.Errorf("%q: %w", hash, ErrInvalidGeohash)
+	}
+
+	lat := [2]float64{-90, 90}
+	long := [2]float64{-180, 180}
+	even := true
+
+	for _, r := range strings.ToLower(hash) {
+		ch := strings.IndexRune(geohashAlphabet, r)
+		if ch < 0 {
+			return BoundingBox{}, fmt.Errorf("%q: %w", hash, ErrInvalidGeohash)
+		}
+
+		for mask := 16; mask > 0; mask >>= 1 {
+			rng := &lat
+			if even {
+				rng = &long
+			}
+
+			mid := (rng[0] + rng[1]) / 2
+			if ch&mask != 0 {
+				rng[0] = mid
+			} else {
+				rng[1] = mid
+			}
+			even = !even
+		}
+	}
+
+	return BoundingBox{South: lat[0], West: long[0], North: lat[1], East: long[1]}, nil
+}
+
+// DecodeGeohash returns the center of hash's cell.
+func DecodeGeohash(hash string) (Coordinate, error) {
+	box, err := GeohashBounds(hash)
+	if err != nil {
+		return Coordinate{}, err
+	}
+	return box.Center(), nil
+}
+
+// GeohashNeighbors returns the cells of the same precision around hash,
+// clockwise from north: N, NE, E, SE, S, SW, W, NW. Cells wrap across the
+// antimeridian; next to a pole the cells beyond it are left out.
+func GeohashNeighbors(hash string) ([]string, error) {
+	box, err := GeohashBounds(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	center := box.Center()
+	height := box.North - box.South
+	width := box.East - box.West
+
+	steps := [][2]float64{{1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}, {0, -1}, {1, -1}}
+
+	neighbors := make([]string, 0, len(steps))
+	for _, step := range steps {
+		lat := center.Lat + step[0]*height
+		if lat > 90 || lat < -90 {
+			continue
+		}
+
+		long := center.Long + step[1]*width
+		switch {
+		case long >= 180:
+			long -= 360
+		case long < -180:
+			long += 360
+		}
+
+		neighbor, err := EncodeGeohash(Coordinate{Lat: lat, Long: long}, len(hash))
+		if err != nil {
+			return nil, err
+		}
+		neighbors = append(neighbors, neighbor)
+	}
+
+	return neighbors, nil
+}
+
+// GeohashCell is the ZIP codes sharing a geohash prefix.
+type GeohashCell struct {
+	Hash   string
+	Bounds BoundingBox
+
+	// Matches are ordered by distance from the center of the cell, so the
+	// first is the cell's most representative ZIP code.
+	Matches []Match
+}
+
+// GroupByGeohash buckets matches by their geohash of precision characters,
+// ordered by hash. Each Match's Distance is reset to its distance from the
+// center of its cell.
+func GroupByGeohash(matches []Match, precision int) ([]GeohashCell, error) {
+	if precision < 1 || precision > MaxGeohashPrecision {
+		return nil, fmt.Errorf("precision %d must be from 1 to %d: %w", precision, MaxGeohashPrecision, ErrInvalidGeohash)
+	}
+
+	cells := make(map[string]*GeohashCell)
+
+	for _, m := range matches {
+		hash, err := EncodeGeohash(m.Coordinate, precision)
+		if err != nil {
+			return nil, fmt.Errorf("zip %q: %w", m.Zip, err)
+		}
+
+		cell, ok := cells[hash]
+		if !ok {
+			bounds, _ := GeohashBounds(hash)
+			cell = &GeohashCell{Hash: hash, Bounds: bounds}
+			cells[hash] = cell
+		}
+
+		m.Distance = Distance(cell.Bounds.Center(), m.Coordinate)
+		cell.Matches = append(cell.Matches, m)
+	}
+
+	grouped := make([]GeohashCell, 0, len(cells))
+	for _, cell := range cells {
+		sortMatches(cell.Matches)
+		grouped = append(grouped, *cell)
+	}
+
+	sort.Slice(grouped, func(i, j int) bool {
+		return grouped[i].Hash < grouped[j].Hash
+	})
+
+	return grouped, nil
+}
+
+// GroupByGeohash buckets every ZIP code in the index by geohash prefix. See
+// the package level GroupByGeohash.
+func (idx *Index) GroupByGeohash(precision int) ([]GeohashCell, error) {
+	matches := make([]Match, 0, len(idx.points))
+	for _, p := range idx.points {
+		matches = append(matches, Match{Country: p.key.Country, Zip: p.key.Code, Coordinate: p.coordinate})
+	}
+	return GroupByGeohash(matches, precision)
+}
@@ -0,0 +1,105 @@
+package location
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestEncodeGeohash(t *testing.T) {
+
+	hash, err := EncodeGeohash(Coordinate{Lat: 57.64911, Long: 10.40744}, 11)
+	require.NoError(t, err)
+	assert.Equal(t, "u4pruydqqvj", hash)
+
+	hash, err = EncodeGeohash(stLouis, 5)
+	require.NoError(t, err)
+	assert.Equal(t, "9yzge", hash)
+
+	_, err = EncodeGeohash(stLouis, 0)
+	assert.True(t, errors.Is(err, ErrInvalidGeohash))
+
+	_, err = EncodeGeohash(Coordinate{Lat: 91}, 5)
+	assert.True(t, errors.Is(err, ErrInvalidLatitude))
+}
+
+func TestDecodeGeohash(t *testing.T) {
+
+	c, err := DecodeGeohash("u4pruydqqvj")
+	require.NoError(t, err)
+	assert.InDelta(t, 57.64911, c.Lat, 1e-5)
+	assert.InDelta(t, 10.40744, c.Long, 1e-5)
+
+	box, err := GeohashBounds("EZS42")
+	require.NoError(t, err)
+	assert.True(t, box.Contains(Coordinate{Lat: 42.6, Long: -5.6}))
+
+	for _, hash := range []string{"", "abc", "u4pruydqqvjxx"} {
+		_, err := DecodeGeohash(hash)
+		assert.True(t, errors.Is(err, ErrInvalidGeohash), hash)
+	}
+}
+
+func TestGeohashNeighbors(t *testing.T) {
+
+	box, err := GeohashBounds("9yzgd")
+	require.NoError(t, err)
+
+	neighbors, err := GeohashNeighbors("9yzgd")
+	require.NoError(t, err)
+	require.Len(t, neighbors, 8)
+
+	north, err := GeohashBounds(neighbors[0])
+	require.NoError(t, err)
+	assert.Equal(t, box.North, north.South)
+	assert.Equal(t, box.West, north.West)
+
+	east, err := GeohashBounds(neighbors[2])
+	require.NoError(t, err)
+	assert.Equal(t, box.East, east.West)
+
+	// east of the antimeridian wraps to the far west
+	edge, err := EncodeGeohash(Coordinate{Lat: 0.1, Long: 179.9}, 3)
+	require.NoError(t, err)
+	neighbors, err = GeohashNeighbors(edge)
+	require.NoError(t, err)
+	east, err = GeohashBounds(neighbors[2])
+	require.NoError(t, err)
+	assert.Equal(t, -180.0, east.West)
+
+	// nothing lies north of the pole
+	top, err := EncodeGeohash(Coordinate{Lat: 89.9, Long: 0}, 2)
+	require.NoError(t, err)
+	neighbors, err = GeohashNeighbors(top)
+	require.NoError(t, err)
+	assert.Len(t, neighbors, 5)
+}
+
+func TestGroupByGeohash(t *testing.T) {
+
+	idx := NewIndex(map[string]Coordinate{
+		"63132": {Lat: 38.676026, Long: -90.377994},
+		"63130": {Lat: 38.665, Long: -90.325},
+		"63101": {Lat: 38.6315, Long: -90.1922},
+		"64105": {Lat: 39.1025, Long: -94.5883},
+	})
+
+	cells, err := idx.GroupByGeohash(3)
+	require.NoError(t, err)
+	require.Len(t, cells, 2)
+
+	assert.Equal(t, "9yu", cells[0].Hash)
+	assert.Len(t, cells[0].Matches, 1)
+	assert.Equal(t, "9yz", cells[1].Hash)
+	require.Len(t, cells[1].Matches, 3)
+
+	for _, m := range cells[1].Matches {
+		assert.True(t, cells[1].Bounds.Contains(m.Coordinate))
+		assert.InDelta(t, Distance(cells[1].Bounds.Center(), m.Coordinate), m.Distance, 1e-9)
+	}
+	assert.True(t, cells[1].Matches[0].Distance <= cells[1].Matches[2].Distance)
+
+	_, err = idx.GroupByGeohash(13)
+	assert.True(t, errors.Is(err, ErrInvalidGeohash))
+}
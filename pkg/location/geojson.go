@@ -0,0 +1,127 @@
+package location
+
+import "sort"
+
+// GeoJSONContentType is the media type for GeoJSON documents, RFC 7946.
+const GeoJSONContentType = "application/geo+json"
+
+// Geometry is a GeoJSON geometry. Coordinates hold longitude before
+// latitude, as GeoJSON requires.
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// Feature is a GeoJSON feature.
+type Feature struct {
+	Type       string                 `json:"type"`
+	Geometry   Geometry               `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// FeatureCollection is a GeoJSON feature collection. BBox, when set, is
+// west, south, east, north.
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	BBox     []float64 `json:"bbox,omitempty"`
+	Features []Feature `json:"features"`
+}
+
+// PointGeometry returns c as a GeoJSON Point.
+func PointGeometry(c Coordinate) Geometry {
+	return Geometry{Type: "Point", Coordinates: []float64{c.Long, c.Lat}}
+}
+
+// NewPointFeature returns a feature at c. A nil properties map is written as
+// an empty object.
+func NewPointFeature(c Coordinate, properties map[string]interface{}) Feature {
+	if properties == nil {
+		properties = make(map[string]interface{})
+	}
+	return Feature{Type: "Feature", Geometry: PointGeometry(c), Properties: properties}
+}
+
+// NewFeatureCollection wraps features, which may be nil, in a collection.
+func NewFeatureCollection(features []Feature) FeatureCollection {
+	if features == nil {
+		features = []Feature{}
+	}
+	return FeatureCollection{Type: "FeatureCollection", Features: features}
+}
+
+// WithBBox returns fc with its bbox member set to box.
+func (fc FeatureCollection) WithBBox(box BoundingBox) FeatureCollection {
+	fc.BBox = []float64{box.West, box.South, box.East, box.North}
+	return fc
+}
+
+// Feature returns p as a point feature with its country, zip and whichever
+// of its descriptive fields are set as properties.
+func (p Place) Feature() Feature {
+	properties := map[string]interface{}{
+		"country": string(p.Country),
+		"zip":     p.Zip,
+	}
+
+	optional := map[string]string{
+		"city":       p.City,
+		"state":      p.State,
+		"countyFips": p.CountyFIPS,
+		"timeZone":   p.TimeZone,
+	}
+	for name, value := range optional {
+		if value != "" {
+			properties[name] = value
+		}
+	}
+
+	return NewPointFeature(p.Coordinate, properties)
+}
+
+// PlacesGeoJSON returns places as a collection of point features, in the
+// order given.
+func PlacesGeoJSON(places []Place) FeatureCollection {
+	features := make([]Feature, 0, len(places))
+	for _, p := range places {
+		features = append(features, p.Feature())
+	}
+	return NewFeatureCollection(features)
+}
+
+// ZipCodesGeoJSON returns a set of US ZIP code centroids as a collection of
+// point features ordered by ZIP code.
+func ZipCodesGeoJSON(zipCodeMap map[string]Coordinate) FeatureCollection {
+	places := make([]Place, 0, len(zipCodeMap))
+	for zip, c := range zipCodeMap {
+		places = append(places, Place{Country: US, Zip: zip, Coordinate: c})
+	}
+
+	sort.Slice(places, func(i, j int) bool {
+		return places[i].Zip < places[j].Zip
+	})
+
+	return PlacesGeoJSON(places)
+}
+
+// MatchPlaces returns the places for matches, in the same order, filled in
+// from r when it is a PostalResolver.
+func MatchPlaces(r Resolver, matches []Match) []Place {
+	pr, _ := r.(PostalResolver)
+
+	places := make([]Place, 0, len(matches))
+	for _, m := range matches {
+		place := Place{Country: m.Country, Zip: m.Zip, Coordinate: m.Coordinate}
+		if place.Country == "" {
+			place.Country = US
+		}
+
+		if pr != nil {
+			if p, err := pr.LookupPostalCode(place.PostalCode()); err == nil {
+				place = p
+			}
+		}
+
+		places = append(places, place)
+	}
+	return places
+}
@@ -0,0 +1,49 @@
+package location
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestZipCodesGeoJSON(t *testing.T) {
+
+	fc := ZipCodesGeoJSON(map[string]Coordinate{
+		"63132": {Lat: 38.676026, Long: -90.377994},
+		"00601": {Lat: 18.180555, Long: -66.749961},
+	})
+
+	b, err := json.Marshal(fc)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"type": "FeatureCollection",
+		"features": [
+			{"type": "Feature", "geometry": {"type": "Point", "coordinates": [-66.749961, 18.180555]}, "properties": {"country": "US", "zip": "00601"}},
+			{"type": "Feature", "geometry": {"type": "Point", "coordinates": [-90.377994, 38.676026]}, "properties": {"country": "US", "zip": "63132"}}
+		]
+	}`, string(b))
+
+	b, err = json.Marshal(ZipCodesGeoJSON(nil).WithBBox(BoundingBox{South: 38, West: -91, North: 39, East: -90}))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type": "FeatureCollection", "bbox": [-91, 38, -90, 39], "features": []}`, string(b))
+}
+
+func TestMatchPlaces(t *testing.T) {
+
+	places, _, err := LoadPlaces("testdata/places.csv", Strict)
+	require.NoError(t, err)
+	idx := NewPlaceIndex(places)
+
+	matches := idx.Nearest(Coordinate{Lat: 38.676026, Long: -90.377994}, 1)
+	got := MatchPlaces(idx, matches)
+
+	require.Len(t, got, 1)
+	assert.Equal(t, "Saint Louis", got[0].City)
+
+	feature := got[0].Feature()
+	assert.Equal(t, "Saint Louis", feature.Properties["city"])
+	assert.Equal(t, "63132", feature.Properties["zip"])
+	assert.NotContains(t, MatchPlaces(MapResolver{}, matches)[0].Feature().Properties, "city")
+}
@@ -0,0 +1,453 @@
+package location
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+const earthRadiusMiles = 3958.8
+
+var ErrZipNotFound = errors.New("zip code not found")
+
+// Match is a ZIP code returned from a spatial query along with its distance
+// in miles from the query point.
+type Match struct {
+	Country    Country
+	Zip        string
+	Coordinate Coordinate
+	Distance   float64
+}
+
+// Index is a k-d tree over ZIP code centroids for nearest neighbour queries.
+// It may hold postal codes from several countries; the methods taking a bare
+// zip string look up US ZIP codes.
+//
+// Points are stored as unit vectors on the sphere so that straight-line
+// (chord) distance orders the same way as great-circle distance, which keeps
+// queries correct near the poles and across the antimeridian.
+type Index struct {
+	points   []indexPoint
+	places   map[PostalCode]Place
+	prefixes map[string]Coordinate
+
+	// search is built on the first Suggest call, most callers never need it
+	namesOnce sync.Once
+	names     *SearchIndex
+}
+
+type indexPoint struct {
+	key        PostalCode
+	coordinate Coordinate
+	vector     [3]float64
+}
+
+// NewIndex builds an index over the ZIP codes in zipCodeMap.
+func NewIndex(zipCodeMap map[string]Coordinate) *Index {
+	places := make(map[PostalCode]Place, len(zipCodeMap))
+	for zip, coordinate := range zipCodeMap {
+		places[PostalCode{Country: US, Code: zip}] = Place{Country: US, Zip: zip, Coordinate: coordinate}
+	}
+	return NewPlaceIndex(places)
+}
+
+// NewPlaceIndex builds an index over places, which must be keyed by their
+// PostalCode.
+func NewPlaceIndex(places map[PostalCode]Place) *Index {
+	points := make([]indexPoint, 0, len(places))
+	copied := make(map[PostalCode]Place, len(places))
+
+	for key, place := range places {
+		copied[key] = place
+		points = append(points, indexPoint{
+			key:        key,
+			coordinate: place.Coordinate,
+			vector:     toVector(place.Coordinate),
+		})
+	}
+
+	// map iteration order is random, sort so the tree shape is reproducible
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].key.Country != points[j].key.Country {
+			return points[i].key.Country < points[j].key.Country
+		}
+		return points[i].key.Code < points[j].key.Code
+	})
+
+	buildTree(points, 0)
+
+	return &Index{points: points, places: copied, prefixes: prefixCentroids(coordinates(copied))}
+}
+
+// Len returns the number of ZIP codes in the index.
+func (idx *Index) Len() int {
+	return len(idx.points)
+}
+
+// Lookup returns the centroid of zip.
+func (idx *Index) Lookup(zip string) (Coordinate, error) {
+	place, err := idx.LookupPlace(zip)
+	return place.Coordinate, err
+}
+
+// LookupPlace returns zip along with its descriptive fields.
+func (idx *Index) LookupPlace(zip string) (Place, error) {
+	return idx.LookupPostalCode(PostalCode{Country: US, Code: zip})
+}
+
+// LookupPostalCode returns the place stored under code.
+func (idx *Index) LookupPostalCode(code PostalCode) (Place, error) {
+	place, ok := idx.places[code]
+	if !ok {
+		if code.Country == US {
+			return Place{}, notFound(code.Code)
+		}
+		return Place{}, notFound(code.String())
+	}
+	return place, nil
+}
+
+// Suggest searches the index's place names. See SearchIndex.Suggest.
+func (idx *Index) Suggest(query string, limit int) []Suggestion {
+	return idx.searchIndex().Suggest(query, limit)
+}
+
+// Exact returns the places named exactly by query. See SearchIndex.Exact.
+func (idx *Index) Exact(query string) []Suggestion {
+	return idx.searchIndex().Exact(query)
+}
+
+// PlaceNames returns the number of distinct city and state pairs in the
+// index, zero if its dataset has no city column.
+func (idx *Index) PlaceNames() int {
+	return idx.searchIndex().Len()
+}
+
+func (idx *Index) searchIndex() *SearchIndex {
+	idx.namesOnce.Do(func() {
+		idx.names = NewSearchIndex(idx.places)
+	})
+	return idx.names
+}
+
+// LookupPrefix returns the centroid of the US ZIP codes starting with zip3.
+func (idx *Index) LookupPrefix(zip3 string) (Coordinate, error) {
+	c, ok := idx.prefixes[zip3]
+	if !ok {
+		return Coordinate{}, notFound(zip3)
+	}
+	return c, nil
+}
+
+func notFound(zip string) error {
+	return fmt.Errorf("%q: %w", zip, ErrZipNotFound)
+}
+
+// Nearest returns up to k ZIP codes closest to c, nearest first.
+func (idx *Index) Nearest(c Coordinate, k int) []Match {
+	if k <= 0 || len(idx.points) == 0 {
+		return nil
+	}
+
+	h := &candidateHeap{}
+	idx.search(0, len(idx.points), 0, toVector(c), k, h)
+
+	matches := make([]Match, h.Len())
+	for i := len(matches) - 1; i >= 0; i-- {
+		cand := heap.Pop(h).(candidate)
+		p := idx.points[cand.index]
+		matches[i] = Match{
+			Country:    p.key.Country,
+			Zip:        p.key.Code,
+			Coordinate: p.coordinate,
+			Distance:   chordToMiles(math.Sqrt(cand.dist2)),
+		}
+	}
+
+	return matches
+}
+
+// Within returns every ZIP code within miles of c, nearest first.
+func (idx *Index) Within(c Coordinate, miles float64) []Match {
+	if miles < 0 {
+		return nil
+	}
+
+	// compare in chord space so the tree can prune on its axes
+	angle := math.Min(miles/earthRadiusMiles, math.Pi)
+	chord := 2 * math.Sin(angle/2)
+
+	var matches []Match
+	idx.searchRadius(0, len(idx.points), 0, toVector(c), chord*chord, func(p indexPoint) {
+		matches = append(matches, Match{
+			Country:    p.key.Country,
+			Zip:        p.key.Code,
+			Coordinate: p.coordinate,
+			Distance:   Distance(c, p.coordinate),
+		})
+	})
+
+	sortMatches(matches)
+
+	return matches
+}
+
+// WithinZip returns every ZIP code within miles of zip, nearest first. The
+// result includes zip itself.
+func (idx *Index) WithinZip(zip string, miles float64) ([]Match, error) {
+	c, err := idx.Lookup(zip)
+	if err != nil {
+		return nil, err
+	}
+	return idx.Within(c, miles), nil
+}
+
+// InBox returns every ZIP code inside box, ordered by distance from the
+// centre of the box.
+func (idx *Index) InBox(box BoundingBox) []Match {
+	center := box.Center()
+
+	var matches []Match
+	idx.visitBox(box, func(p indexPoint) {
+		matches = append(matches, Match{
+			Country:    p.key.Country,
+			Zip:        p.key.Code,
+			Coordinate: p.coordinate,
+			Distance:   Distance(center, p.coordinate),
+		})
+	})
+
+	sortMatches(matches)
+
+	return matches
+}
+
+// visitBox calls visit for every point inside box. The tree is searched for
+// points inside a box in vector space around the lat/long box, and those are
+// then checked against box itself.
+func (idx *Index) visitBox(box BoundingBox, visit func(indexPoint)) {
+	min, max := vectorBounds(box)
+
+	idx.searchBox(0, len(idx.points), 0, min, max, func(p indexPoint) {
+		if box.Contains(p.coordinate) {
+			visit(p)
+		}
+	})
+}
+
+func (idx *Index) searchBox(lo, hi, depth int, min, max [3]float64, visit func(indexPoint)) {
+	if lo >= hi {
+		return
+	}
+
+	mid := (lo + hi) / 2
+	p := idx.points[mid]
+
+	if p.vector[0] >= min[0] && p.vector[0] <= max[0] &&
+		p.vector[1] >= min[1] && p.vector[1] <= max[1] &&
+		p.vector[2] >= min[2] && p.vector[2] <= max[2] {
+		visit(p)
+	}
+
+	axis := depth % 3
+
+	if min[axis] <= p.vector[axis] {
+		idx.searchBox(lo, mid, depth+1, min, max, visit)
+	}
+	if max[axis] >= p.vector[axis] {
+		idx.searchBox(mid+1, hi, depth+1, min, max, visit)
+	}
+}
+
+// vectorBounds returns the corners of a box in vector space holding every
+// point of the lat/long box, padded a little for rounding.
+func vectorBounds(box BoundingBox) (min, max [3]float64) {
+	south, north := radians(box.South), radians(box.North)
+
+	// cos(lat) scales x and y, and is never negative
+	cosLo, cosHi := math.Min(math.Cos(south), math.Cos(north)), math.Max(math.Cos(south), math.Cos(north))
+	if south <= 0 && north >= 0 {
+		cosHi = 1
+	}
+
+	west, east := radians(box.West), radians(box.East)
+	if east < west {
+		east += 2 * math.Pi
+	}
+
+	// cos and sin of longitude peak at the ends or at a multiple of 90°
+	longs := []float64{west, east}
+	for k := math.Ceil(west / (math.Pi / 2)); k*math.Pi/2 <= east; k++ {
+		longs = append(longs, k*math.Pi/2)
+	}
+
+	xLo, xHi, yLo, yHi := 1.0, -1.0, 1.0, -1.0
+	for _, long := range longs {
+		xLo, xHi = math.Min(xLo, math.Cos(long)), math.Max(xHi, math.Cos(long))
+		yLo, yHi = math.Min(yLo, math.Sin(long)), math.Max(yHi, math.Sin(long))
+	}
+
+	const pad = 1e-9
+
+	min = [3]float64{
+		math.Min(cosLo*xLo, cosHi*xLo) - pad,
+		math.Min(cosLo*yLo, cosHi*yLo) - pad,
+		math.Sin(south) - pad,
+	}
+	max = [3]float64{
+		math.Max(cosLo*xHi, cosHi*xHi) + pad,
+		math.Max(cosLo*yHi, cosHi*yHi) + pad,
+		math.Sin(north) + pad,
+	}
+	return min, max
+}
+
+func (idx *Index) searchRadius(lo, hi, depth int, q [3]float64, limit2 float64, visit func(indexPoint)) {
+	if lo >= hi {
+		return
+	}
+
+	mid := (lo + hi) / 2
+	p := idx.points[mid]
+
+	if distance2(q, p.vector) <= limit2 {
+		visit(p)
+	}
+
+	axis := depth % 3
+	diff := q[axis] - p.vector[axis]
+
+	if diff < 0 || diff*diff <= limit2 {
+		idx.searchRadius(lo, mid, depth+1, q, limit2, visit)
+	}
+	if diff >= 0 || diff*diff <= limit2 {
+		idx.searchRadius(mid+1, hi, depth+1, q, limit2, visit)
+	}
+}
+
+func sortMatches(matches []Match) {
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Distance != matches[j].Distance {
+			return matches[i].Distance < matches[j].Distance
+		}
+		return matches[i].Zip < matches[j].Zip
+	})
+}
+
+// search visits the subtree stored in points[lo:hi], whose splitting axis is
+// depth % 3, keeping the k closest candidates seen so far in h.
+func (idx *Index) search(lo, hi, depth int, q [3]float64, k int, h *candidateHeap) {
+	if lo >= hi {
+		return
+	}
+
+	mid := (lo + hi) / 2
+	p := idx.points[mid]
+
+	h.offer(candidate{index: mid, dist2: distance2(q, p.vector)}, k)
+
+	axis := depth % 3
+	diff := q[axis] - p.vector[axis]
+
+	if diff < 0 {
+		idx.search(lo, mid, depth+1, q, k, h)
+		if h.Len() < k || diff*diff < h.worst() {
+			idx.search(mid+1, hi, depth+1, q, k, h)
+		}
+	} else {
+		idx.search(mid+1, hi, depth+1, q, k, h)
+		if h.Len() < k || diff*diff < h.worst() {
+			idx.search(lo, mid, depth+1, q, k, h)
+		}
+	}
+}
+
+// buildTree arranges points in place so that the median of every range is
+// the splitting node for that range.
+func buildTree(points []indexPoint, depth int) {
+	if len(points) <= 1 {
+		return
+	}
+
+	axis := depth % 3
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].vector[axis] < points[j].vector[axis]
+	})
+
+	mid := len(points) / 2
+	buildTree(points[:mid], depth+1)
+	buildTree(points[mid+1:], depth+1)
+}
+
+func toVector(c Coordinate) [3]float64 {
+	lat := radians(c.Lat)
+	long := radians(c.Long)
+	return [3]float64{
+		math.Cos(lat) * math.Cos(long),
+		math.Cos(lat) * math.Sin(long),
+		math.Sin(lat),
+	}
+}
+
+// fromVector converts a vector, which need not be of unit length, back to a
+// coordinate.
+func fromVector(v [3]float64) Coordinate {
+	return Coordinate{
+		Lat:  degrees(math.Atan2(v[2], math.Hypot(v[0], v[1]))),
+		Long: degrees(math.Atan2(v[1], v[0])),
+	}
+}
+
+func distance2(a, b [3]float64) float64 {
+	dx, dy, dz := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return dx*dx + dy*dy + dz*dz
+}
+
+// chordToMiles converts a chord length on the unit sphere to a great-circle
+// distance on the earth.
+func chordToMiles(chord float64) float64 {
+	return 2 * math.Asin(math.Min(chord/2, 1)) * earthRadiusMiles
+}
+
+type candidate struct {
+	index int
+	dist2 float64
+}
+
+// candidateHeap is a max-heap on distance so the worst candidate can be
+// evicted when a closer one is found.
+type candidateHeap []candidate
+
+func (h candidateHeap) Len() int { return len(h) }
+func (h candidateHeap) Less(i, j int) bool {
+	if h[i].dist2 != h[j].dist2 {
+		return h[i].dist2 > h[j].dist2
+	}
+	return h[i].index > h[j].index
+}
+func (h candidateHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *candidateHeap) Push(x interface{}) { *h = append(*h, x.(candidate)) }
+func (h *candidateHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+func (h candidateHeap) worst() float64 {
+	return h[0].dist2
+}
+
+func (h *candidateHeap) offer(c candidate, k int) {
+	if h.Len() < k {
+		heap.Push(h, c)
+		return
+	}
+	if c.dist2 < h.worst() {
+		(*h)[0] = c
+		heap.Fix(h, 0)
+	}
+}
@@ -0,0 +1,61 @@
+package location
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestIndexNearest(t *testing.T) {
+
+	idx, err := LoadZipCodeIndex("zipdata/zip.csv")
+	require.NoError(t, err)
+
+	matches := idx.Nearest(Coordinate{Lat: 18.1806, Long: -66.75}, 3)
+
+	require.Len(t, matches, 3)
+	assert.Equal(t, "00601", matches[0].Zip)
+	assert.InDelta(t, 0, matches[0].Distance, 0.1)
+	assert.True(t, matches[0].Distance <= matches[1].Distance)
+	assert.True(t, matches[1].Distance <= matches[2].Distance)
+}
+
+func TestIndexNearestMatchesLinearScan(t *testing.T) {
+
+	zipCodeMap, err := LoadZipCodeMap("zipdata/zip.csv")
+	require.NoError(t, err)
+
+	idx := NewIndex(zipCodeMap)
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 50; i++ {
+		q := Coordinate{Lat: 20 + r.Float64()*45, Long: -160 + r.Float64()*95}
+
+		type scored struct {
+			zip  string
+			dist float64
+		}
+		var all []scored
+		for zip, c := range zipCodeMap {
+			all = append(all, scored{zip, chordToMiles(math.Sqrt(distance2(toVector(q), toVector(c))))})
+		}
+		sort.Slice(all, func(i, j int) bool { return all[i].dist < all[j].dist })
+
+		matches := idx.Nearest(q, 5)
+		require.Len(t, matches, 5)
+		for j := range matches {
+			assert.InDelta(t, all[j].dist, matches[j].Distance, 1e-9, "query %v rank %d", q, j)
+		}
+	}
+}
+
+func TestIndexNearestEmpty(t *testing.T) {
+
+	idx := NewIndex(map[string]Coordinate{})
+
+	assert.Empty(t, idx.Nearest(Coordinate{}, 1))
+	assert.Empty(t, NewIndex(map[string]Coordinate{"00601": {Lat: 18, Long: -66}}).Nearest(Coordinate{}, 0))
+}
@@ -2,45 +2,362 @@ package location
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 )
 
-type Coordinate struct {
-	Lat  string
-	Long string
+// Mode controls how loaders treat malformed rows.
+type Mode int
+
+const (
+	// Strict stops at the first malformed row and returns it as the error.
+	Strict Mode = iota
+
+	// Lenient skips malformed rows and lists them in the LoadReport.
+	Lenient
+)
+
+var (
+	ErrMissingColumn = errors.New("missing column")
+	ErrDuplicateZip  = errors.New("duplicate zip code")
+	ErrEmptyZip      = errors.New("empty zip code")
+)
+
+// Header names accepted for each column, compared case-insensitively.
+var (
+	zipColumnNames  = []string{"zip", "zipcode", "zip_code", "zip code", "zip5", "postal_code", "postalcode", "postcode", "zcta", "zcta5", "geoid"}
+	latColumnNames  = []string{"lat", "latitude", "intptlat"}
+	longColumnNames = []string{"lng", "lon", "long", "longitude", "intptlong"}
+
+	cityColumnNames     = []string{"city", "primary_city", "po_name", "place", "place_name"}
+	stateColumnNames    = []string{"state", "st", "state_id", "state_abbr", "stusps"}
+	countyColumnNames   = []string{"county_fips", "countyfp", "county_fips_code", "fips"}
+	timeZoneColumnNames = []string{"timezone", "time_zone", "tz", "iana_tz"}
+	countryColumnNames  = []string{"country", "country_code", "iso_country", "iso2"}
+)
+
+// Place is a ZIP code with its centroid and whatever descriptive fields the
+// dataset carries. Fields the dataset has no column for are left empty.
+type Place struct {
+	Country Country
+
+	// Zip is the postal code in the canonical form for Country, see
+	// NormalizePostalCode. For the US it is a five digit ZIP code.
+	Zip        string
+	Coordinate Coordinate
+
+	City  string
+	State string
+
+	// CountyFIPS is the five digit state and county FIPS code, e.g. "29189".
+	CountyFIPS string
+
+	// TimeZone is an IANA time zone name, e.g. "America/Chicago".
+	TimeZone string
 }
 
-func (c Coordinate) String() string {
-	return c.Lat + "," + c.Long
+// PostalCode returns the key place is stored under.
+func (p Place) PostalCode() PostalCode {
+	return PostalCode{Country: p.Country, Code: p.Zip}
 }
 
-func LoadZipCodeMap(filename string) (zipCodeMap map[string]Coordinate, err error) {
-	f, err := os.Open(filename)
+// RowError describes a malformed row in a dataset.
+type RowError struct {
+	Name string
+	Line int
+	Zip  string
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	if e.Zip == "" {
+		return fmt.Sprintf("%s:%d: %v", e.Name, e.Line, e.Err)
+	}
+	return fmt.Sprintf("%s:%d: zip %q: %v", e.Name, e.Line, e.Zip, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// LoadReport summarises a load.
+type LoadReport struct {
+	// Rows is the number of data rows read, not counting the header.
+	Rows int
 
+	// Skipped lists the rows a Lenient load left out.
+	Skipped []*RowError
+}
+
+// Decoder reads ZIP code rows one at a time from a CSV stream, locating the
+// ZIP, latitude and longitude columns, and the optional city, state, county,
+// time zone and country columns, by header name.
+//
+// Postal codes are normalized with the rules for the row's country, which is
+// DefaultCountry when the dataset has no country column.
+type Decoder struct {
+	DefaultCountry Country
+
+	name   string
+	reader *csv.Reader
+
+	zipColumn  int
+	latColumn  int
+	longColumn int
+
+	cityColumn     int
+	stateColumn    int
+	countyColumn   int
+	timeZoneColumn int
+	countryColumn  int
+
+	// raw is the postal code of the last row as written
+	raw string
+}
+
+// NewDecoder reads the header row from r. name is used in error messages.
+func NewDecoder(r io.Reader, name string) (*Decoder, error) {
+	return newDecoder(r, name, ',')
+}
+
+func newDecoder(r io.Reader, name string, comma rune) (*Decoder, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%s: no header row", name)
+	}
 	if err != nil {
-		fmt.Println("Could not open zip.csv", err)
-		return nil, err
+		return nil, fmt.Errorf("%s: reading header: %w", name, err)
+	}
+
+	d := &Decoder{DefaultCountry: US, name: name, reader: reader}
+
+	columns := []struct {
+		index *int
+		names []string
+	}{
+		{&d.zipColumn, zipColumnNames},
+		{&d.latColumn, latColumnNames},
+		{&d.longColumn, longColumnNames},
+	}
+
+	for _, column := range columns {
+		*column.index = findColumn(header, column.names)
+		if *column.index < 0 {
+			return nil, fmt.Errorf("%s: no %s column in header %q: %w", name, column.names[0], header, ErrMissingColumn)
+		}
+	}
+
+	d.cityColumn = findColumn(header, cityColumnNames)
+	d.stateColumn = findColumn(header, stateColumnNames)
+	d.countyColumn = findColumn(header, countyColumnNames)
+	d.timeZoneColumn = findColumn(header, timeZoneColumnNames)
+	d.countryColumn = findColumn(header, countryColumnNames)
+
+	return d, nil
+}
+
+func findColumn(header []string, names []string) int {
+	for _, name := range names {
+		for i, h := range header {
+			h = strings.TrimPrefix(h, "\ufeff")
+			if strings.EqualFold(strings.TrimSpace(h), name) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Next returns the next row. At the end of the input it returns io.EOF. A
+// malformed row is returned as a *RowError, after which Next may be called
+// again to continue with the following row; any other error is fatal.
+func (d *Decoder) Next() (Place, error) {
+	record, err := d.reader.Read()
+
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return Place{}, &RowError{Name: d.name, Line: parseErr.Line, Err: parseErr.Err}
+		}
+		return Place{}, err
+	}
+
+	line, _ := d.reader.FieldPos(0)
+
+	for _, column := range []int{d.zipColumn, d.latColumn, d.longColumn} {
+		if column >= len(record) {
+			return Place{}, &RowError{Name: d.name, Line: line, Err: fmt.Errorf("%d fields: %w", len(record), csv.ErrFieldCount)}
+		}
+	}
+
+	place := Place{Country: d.DefaultCountry}
+
+	raw := strings.TrimSpace(record[d.zipColumn])
+	d.raw = raw
+	if raw == "" {
+		return Place{}, &RowError{Name: d.name, Line: line, Err: ErrEmptyZip}
+	}
+
+	if country := optionalField(record, d.countryColumn); country != "" {
+		place.Country, err = ParseCountry(country)
+		if err != nil {
+			return Place{}, &RowError{Name: d.name, Line: line, Zip: raw, Err: err}
+		}
+	}
+
+	place.Zip, err = NormalizePostalCode(place.Country, raw)
+	if err != nil {
+		return Place{}, &RowError{Name: d.name, Line: line, Zip: raw, Err: err}
+	}
+
+	place.Coordinate, err = ParseCoordinate(record[d.latColumn], record[d.longColumn])
+	if err != nil {
+		return Place{}, &RowError{Name: d.name, Line: line, Zip: place.Zip, Err: err}
+	}
+
+	place.City = optionalField(record, d.cityColumn)
+	place.State = strings.ToUpper(optionalField(record, d.stateColumn))
+	place.CountyFIPS = optionalField(record, d.countyColumn)
+	place.TimeZone = optionalField(record, d.timeZoneColumn)
+
+	// spreadsheets drop the leading zero of Alabama through Connecticut
+	if len(place.CountyFIPS) == 4 && isDigits(place.CountyFIPS) {
+		place.CountyFIPS = "0" + place.CountyFIPS
 	}
 
-	csvReader := csv.NewReader(f)
+	return place, nil
+}
+
+func optionalField(record []string, column int) string {
+	if column < 0 || column >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[column])
+}
+
+// Line returns the line of the most recently returned row.
+func (d *Decoder) Line() int {
+	line, _ := d.reader.FieldPos(0)
+	return line
+}
 
-	records, csvReadErr := csvReader.ReadAll()
+// RawZip returns the postal code of the most recently returned row as it was
+// written, before normalization.
+func (d *Decoder) RawZip() string {
+	return d.raw
+}
 
-	if csvReadErr != nil {
-		fmt.Println("Could not read zip.csv")
-		return
+// ReadPlaces reads a ZIP code CSV stream into a map keyed by country and
+// postal code. In Strict mode the first malformed or duplicate row is
+// returned as the error; in Lenient mode such rows are skipped and listed in
+// the report.
+func ReadPlaces(r io.Reader, name string, mode Mode) (map[PostalCode]Place, LoadReport, error) {
+	decoder, err := NewDecoder(r, name)
+	if err != nil {
+		return nil, LoadReport{}, err
 	}
+	return readPlaces(decoder, name, mode)
+}
 
-	zipCodeMap = make(map[string]Coordinate)
+func readPlaces(decoder *Decoder, name string, mode Mode) (map[PostalCode]Place, LoadReport, error) {
+	var report LoadReport
 
-	for _, record := range records[1:] {
+	places := make(map[PostalCode]Place)
+	lines := make(map[PostalCode]int)
 
-		zipCodeMap[record[0]] = Coordinate{
-			Lat:  record[1],
-			Long: record[2],
+	for {
+		place, err := decoder.Next()
+		if err == io.EOF {
+			break
 		}
+
+		var rowErr *RowError
+		if err == nil {
+			report.Rows++
+			if first, ok := lines[place.PostalCode()]; ok {
+				rowErr = &RowError{Name: name, Line: decoder.Line(), Zip: place.Zip, Err: fmt.Errorf("%w, first seen on line %d", ErrDuplicateZip, first)}
+			}
+		} else if errors.As(err, &rowErr) {
+			report.Rows++
+		} else {
+			return nil, report, fmt.Errorf("%s: %w", name, err)
+		}
+
+		if rowErr != nil {
+			if mode == Strict {
+				return nil, report, rowErr
+			}
+			report.Skipped = append(report.Skipped, rowErr)
+			continue
+		}
+
+		places[place.PostalCode()] = place
+		lines[place.PostalCode()] = decoder.Line()
+	}
+
+	return places, report, nil
+}
+
+// LoadPlaces reads a ZIP code CSV file. See ReadPlaces.
+func LoadPlaces(filename string, mode Mode) (map[PostalCode]Place, LoadReport, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, LoadReport{}, err
 	}
+	defer f.Close()
+
+	return ReadPlaces(f, filename, mode)
+}
+
+// ReadZipCodes is ReadPlaces keeping only the coordinates of US ZIP codes.
+func ReadZipCodes(r io.Reader, name string, mode Mode) (map[string]Coordinate, LoadReport, error) {
+	places, report, err := ReadPlaces(r, name, mode)
+	if err != nil {
+		return nil, report, err
+	}
+	return coordinates(places), report, nil
+}
 
-	return
+// LoadZipCodes is LoadPlaces keeping only the coordinates of US ZIP codes.
+func LoadZipCodes(filename string, mode Mode) (map[string]Coordinate, LoadReport, error) {
+	places, report, err := LoadPlaces(filename, mode)
+	if err != nil {
+		return nil, report, err
+	}
+	return coordinates(places), report, nil
+}
+
+func coordinates(places map[PostalCode]Place) map[string]Coordinate {
+	zipCodeMap := make(map[string]Coordinate, len(places))
+	for key, place := range places {
+		if key.Country == US {
+			zipCodeMap[key.Code] = place.Coordinate
+		}
+	}
+	return zipCodeMap
+}
+
+// LoadZipCodeMap reads a ZIP code CSV file in Strict mode.
+func LoadZipCodeMap(filename string) (map[string]Coordinate, error) {
+	zipCodeMap, _, err := LoadZipCodes(filename, Strict)
+	return zipCodeMap, err
+}
+
+// LoadZipCodeIndex loads a ZIP code CSV file in Strict mode and indexes it,
+// keeping any descriptive fields the file has.
+func LoadZipCodeIndex(filename string) (*Index, error) {
+	places, _, err := LoadPlaces(filename, Strict)
+	if err != nil {
+		return nil, err
+	}
+	return NewPlaceIndex(places), nil
 }
@@ -1,15 +1,140 @@
 package location
 
 import (
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"strings"
 	"testing"
 )
 
 func TestZipCodeLoad(t *testing.T) {
 
-	zipCodeMap, err := LoadZipCodeMap("testdata/zip.csv")
+	zipCodeMap, err := LoadZipCodeMap("zipdata/zip.csv")
 
 	require.NoError(t, err)
 	assert.NotEmpty(t, zipCodeMap)
 }
+
+func TestZipCodeLoadRejectsInvalidRows(t *testing.T) {
+
+	_, err := LoadZipCodeMap("testdata/invalid.csv")
+
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrInvalidLatitude))
+	assert.Contains(t, err.Error(), "testdata/invalid.csv:3")
+}
+
+func TestZipCodeLoadMissingFile(t *testing.T) {
+
+	zipCodeMap, err := LoadZipCodeMap("testdata/missing.csv")
+
+	assert.Error(t, err)
+	assert.Nil(t, zipCodeMap)
+}
+
+func TestReadZipCodesFindsColumnsByHeader(t *testing.T) {
+
+	input := "\ufeffLatitude, Longitude ,City,ZIP_CODE\n" +
+		"18.180555,-66.749961,Adjuntas,00601\n" +
+		"38.676026,-90.377994,\"St. Louis\",63132\n"
+
+	zipCodeMap, report, err := ReadZipCodes(strings.NewReader(input), "input", Strict)
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Rows)
+	assert.Equal(t, map[string]Coordinate{
+		"00601": {Lat: 18.180555, Long: -66.749961},
+		"63132": {Lat: 38.676026, Long: -90.377994},
+	}, zipCodeMap)
+}
+
+func TestReadZipCodesMissingColumn(t *testing.T) {
+
+	_, _, err := ReadZipCodes(strings.NewReader("ZIP,LAT\n00601,18.1\n"), "input", Lenient)
+
+	assert.True(t, errors.Is(err, ErrMissingColumn))
+
+	_, _, err = ReadZipCodes(strings.NewReader(""), "input", Lenient)
+
+	assert.Error(t, err)
+}
+
+const malformedInput = `ZIP,LAT,LNG
+00601,18.180555,-66.749961
+00602,north,-67.175597
+00603,18.455183
+00604,18.4,"-67.1"x
+00601,18.2,-66.7
+,18.2,-66.7
+00606,18.158345,-66.932911
+`
+
+func TestReadZipCodesStrict(t *testing.T) {
+
+	zipCodeMap, _, err := ReadZipCodes(strings.NewReader(malformedInput), "input", Strict)
+
+	require.Error(t, err)
+	assert.Nil(t, zipCodeMap)
+
+	var rowErr *RowError
+	require.True(t, errors.As(err, &rowErr))
+	assert.Equal(t, 3, rowErr.Line)
+	assert.Equal(t, "00602", rowErr.Zip)
+	assert.Equal(t, `input:3: zip "00602": latitude "north": invalid syntax`, err.Error())
+}
+
+func TestReadZipCodesLenient(t *testing.T) {
+
+	zipCodeMap, report, err := ReadZipCodes(strings.NewReader(malformedInput), "input", Lenient)
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Coordinate{
+		"00601": {Lat: 18.180555, Long: -66.749961},
+		"00606": {Lat: 18.158345, Long: -66.932911},
+	}, zipCodeMap)
+	assert.Equal(t, 7, report.Rows)
+
+	var lines []int
+	for _, rowErr := range report.Skipped {
+		lines = append(lines, rowErr.Line)
+	}
+	assert.Equal(t, []int{3, 4, 5, 6, 7}, lines)
+	assert.True(t, errors.Is(report.Skipped[3], ErrDuplicateZip))
+	assert.True(t, errors.Is(report.Skipped[4], ErrEmptyZip))
+}
+
+func TestLoadPlacesOptionalColumns(t *testing.T) {
+
+	idx, err := LoadZipCodeIndex("testdata/places.csv")
+	require.NoError(t, err)
+
+	place, err := idx.LookupPlace("00680")
+	require.NoError(t, err)
+	assert.Equal(t, Place{
+		Country:    US,
+		Zip:        "00680",
+		Coordinate: Coordinate{Lat: 18.2054, Long: -67.1278},
+		City:       "Mayagüez",
+		State:      "PR",
+		CountyFIPS: "72097",
+		TimeZone:   "America/Puerto_Rico",
+	}, place)
+
+	place, err = idx.LookupPlace("01001")
+	require.NoError(t, err)
+	assert.Equal(t, "01013", place.CountyFIPS)
+
+	place, err = idx.LookupPlace("96701")
+	require.NoError(t, err)
+	assert.Equal(t, "", place.TimeZone)
+
+	res, err := Resolve(idx, "1001")
+	require.NoError(t, err)
+	assert.Equal(t, "Agawam", res.Place.City)
+
+	// the plain dataset has none of the optional columns
+	places, _, err := LoadPlaces("zipdata/zip.csv", Strict)
+	require.NoError(t, err)
+	assert.Equal(t, Place{Country: US, Zip: "00601", Coordinate: Coordinate{Lat: 18.180555, Long: -66.749961}}, places[PostalCode{Country: US, Code: "00601"}])
+}
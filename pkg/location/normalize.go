@@ -0,0 +1,209 @@
+package location
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidZip = errors.New("invalid zip code")
+
+// NormalizeZip returns the canonical five digit form of a US ZIP code as
+// people and spreadsheets tend to write it:
+//
+//	" 00601 "    -> "00601"
+//	"00601-1234" -> "00601" (ZIP+4)
+//	"006011234"  -> "00601" (ZIP+4 without the dash)
+//	"601"        -> "00601" (leading zeros lost)
+//	"6011234"    -> "00601" (ZIP+4 with leading zeros lost)
+func NormalizeZip(s string) (string, error) {
+	zip := strings.TrimSpace(s)
+	plus4 := ""
+
+	if i := strings.IndexAny(zip, "- "); i >= 0 {
+		zip, plus4 = strings.TrimSpace(zip[:i]), strings.TrimSpace(zip[i+1:])
+		if len(plus4) != 4 || !isDigits(plus4) {
+			return "", fmt.Errorf("%q: %w", s, ErrInvalidZip)
+		}
+	}
+
+	if !isDigits(zip) {
+		return "", fmt.Errorf("%q: %w", s, ErrInvalidZip)
+	}
+
+	switch {
+	case len(zip) >= 3 && len(zip) <= 5:
+		return strings.Repeat("0", 5-len(zip)) + zip, nil
+	case plus4 == "" && len(zip) >= 7 && len(zip) <= 9:
+		return (strings.Repeat("0", 9-len(zip)) + zip)[:5], nil
+	}
+
+	return "", fmt.Errorf("%q: %w", s, ErrInvalidZip)
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// PrefixResolver is implemented by resolvers that can approximate a ZIP code
+// they do not know by the centroid of its three digit ZIP3 prefix.
+type PrefixResolver interface {
+	LookupPrefix(zip3 string) (Coordinate, error)
+}
+
+// PlaceResolver is implemented by resolvers that carry descriptive fields
+// such as the city and time zone of each ZIP code.
+type PlaceResolver interface {
+	LookupPlace(zip string) (Place, error)
+}
+
+// PostalResolver is implemented by resolvers that hold postal codes for
+// countries other than the US.
+type PostalResolver interface {
+	LookupPostalCode(code PostalCode) (Place, error)
+}
+
+// Resolution is the result of Resolve.
+type Resolution struct {
+	// Zip is the normalized form of the input.
+	Zip        string
+	Coordinate Coordinate
+
+	// Place holds Zip and Coordinate along with the descriptive fields the
+	// resolver has, if it is a PlaceResolver. Approximate resolutions have
+	// no descriptive fields.
+	Place Place
+
+	// Approximate is set when Zip itself was not found and Coordinate is
+	// the centroid of its ZIP3 prefix instead.
+	Approximate bool
+
+	// FromCoordinate is set when the input was a coordinate. Coordinate is
+	// the input itself, and Zip and Place describe the nearest ZIP code if
+	// there is one within maxNearestDistance miles.
+	FromCoordinate bool
+}
+
+// Resolve normalizes input with NormalizeZip and looks it up in r. If the
+// exact ZIP code is missing and r is a PrefixResolver, it falls back to the
+// ZIP3 prefix centroid.
+func Resolve(r Resolver, input string) (Resolution, error) {
+	zip, err := NormalizeZip(input)
+	if err != nil {
+		return Resolution{}, err
+	}
+
+	var c Coordinate
+	var place Place
+
+	if pr, ok := r.(PlaceResolver); ok {
+		place, err = pr.LookupPlace(zip)
+		c = place.Coordinate
+	} else {
+		c, err = r.Lookup(zip)
+		place = Place{Country: US, Zip: zip, Coordinate: c}
+	}
+
+	if err == nil {
+		return Resolution{Zip: zip, Coordinate: c, Place: place}, nil
+	}
+
+	pr, ok := r.(PrefixResolver)
+	if !ok || !errors.Is(err, ErrZipNotFound) {
+		return Resolution{}, err
+	}
+
+	c, prefixErr := pr.LookupPrefix(zip[:3])
+	if prefixErr != nil {
+		return Resolution{}, err
+	}
+
+	return Resolution{Zip: zip, Coordinate: c, Place: Place{Country: US, Zip: zip, Coordinate: c}, Approximate: true}, nil
+}
+
+// ResolveCoordinate describes c by the nearest ZIP code in r.
+func ResolveCoordinate(r Resolver, c Coordinate) Resolution {
+	res := Resolution{Coordinate: c, Place: Place{Coordinate: c}, FromCoordinate: true}
+
+	matches := r.Nearest(c, 1)
+	if len(matches) == 0 || matches[0].Distance > maxNearestDistance {
+		return res
+	}
+
+	place := Place{Country: matches[0].Country, Zip: matches[0].Zip}
+	if place.Country == "" {
+		place.Country = US
+	}
+	if pr, ok := r.(PostalResolver); ok {
+		if p, err := pr.LookupPostalCode(place.PostalCode()); err == nil {
+			place = p
+		}
+	}
+	place.Coordinate = c
+
+	res.Zip = place.Zip
+	res.Place = place
+
+	return res
+}
+
+// ResolvePostalCode normalizes input with the rules for country and looks it
+// up in r. US ZIP codes go through Resolve and so can fall back to their
+// ZIP3 prefix; other countries need r to be a PostalResolver, and the
+// error wraps ErrUnknownCountry if it is not.
+func ResolvePostalCode(r Resolver, country Country, input string) (Resolution, error) {
+	if country == US {
+		return Resolve(r, input)
+	}
+
+	code, err := NormalizePostalCode(country, input)
+	if err != nil {
+		return Resolution{}, err
+	}
+
+	key := PostalCode{Country: country, Code: code}
+
+	// snapshots and plain ZIP code maps hold US ZIP codes only
+	pr, ok := r.(PostalResolver)
+	if !ok {
+		return Resolution{}, fmt.Errorf("%s: dataset has only US ZIP codes: %w", key, ErrUnknownCountry)
+	}
+
+	place, err := pr.LookupPostalCode(key)
+	if err != nil {
+		return Resolution{}, err
+	}
+
+	return Resolution{Zip: code, Coordinate: place.Coordinate, Place: place}, nil
+}
+
+// prefixCentroids returns the mean position of the ZIP codes under each ZIP3
+// prefix, averaged as unit vectors so prefixes spanning the antimeridian
+// come out right.
+func prefixCentroids(zipCodeMap map[string]Coordinate) map[string]Coordinate {
+	sums := make(map[string][3]float64)
+
+	for zip, c := range zipCodeMap {
+		if len(zip) < 3 {
+			continue
+		}
+		v := toVector(c)
+		sum := sums[zip[:3]]
+		sums[zip[:3]] = [3]float64{sum[0] + v[0], sum[1] + v[1], sum[2] + v[2]}
+	}
+
+	centroids := make(map[string]Coordinate, len(sums))
+	for prefix, sum := range sums {
+		centroids[prefix] = fromVector(sum)
+	}
+
+	return centroids
+}
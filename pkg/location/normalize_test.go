@@ -0,0 +1,83 @@
+package location
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+)
+
+func TestNormalizeZip(t *testing.T) {
+
+	valid := map[string]string{
+		"00601":       "00601",
+		" 00601 ":     "00601",
+		"601":         "00601",
+		"2134":        "02134",
+		"00601-1234":  "00601",
+		"00601 1234":  "00601",
+		"601-1234":    "00601",
+		"006011234":   "00601",
+		"6011234":     "00601",
+		"21341234":    "02134",
+		"63132\n":     "63132",
+		"63132-0001 ": "63132",
+	}
+
+	for input, expected := range valid {
+		zip, err := NormalizeZip(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, zip, input)
+	}
+
+	for _, input := range []string{"", "12", "123456", "0060112345", "abcde", "00601-12", "00601-abcd", "-1234", "6.01"} {
+		_, err := NormalizeZip(input)
+		assert.True(t, errors.Is(err, ErrInvalidZip), input)
+	}
+}
+
+func TestResolve(t *testing.T) {
+
+	zips := MapResolver{
+		"00601": {Lat: 18.180555, Long: -66.749961},
+		"00602": {Lat: 18.361945, Long: -67.175597},
+		"63132": {Lat: 38.676026, Long: -90.377994},
+	}
+	idx := NewIndex(zips)
+
+	for _, r := range []Resolver{zips, idx} {
+
+		res, err := Resolve(r, " 601-1234")
+		require.NoError(t, err)
+		assert.Equal(t, Resolution{
+			Zip:        "00601",
+			Coordinate: zips["00601"],
+			Place:      Place{Country: US, Zip: "00601", Coordinate: zips["00601"]},
+		}, res)
+
+		res, err = Resolve(r, "00699")
+		require.NoError(t, err)
+		assert.True(t, res.Approximate)
+		assert.Equal(t, "00699", res.Zip)
+		assert.True(t, Distance(res.Coordinate, zips["00601"]) < 30)
+		assert.True(t, Distance(res.Coordinate, zips["00602"]) < 30)
+
+		_, err = Resolve(r, "99999")
+		assert.True(t, errors.Is(err, ErrZipNotFound))
+
+		_, err = Resolve(r, "zip")
+		assert.True(t, errors.Is(err, ErrInvalidZip))
+	}
+}
+
+func TestPrefixCentroidsAcrossAntimeridian(t *testing.T) {
+
+	centroids := prefixCentroids(map[string]Coordinate{
+		"99501": {Lat: 50, Long: 179},
+		"99502": {Lat: 50, Long: -179},
+	})
+
+	assert.InDelta(t, 50, centroids["995"].Lat, 0.01)
+	assert.InDelta(t, 180, math.Abs(centroids["995"].Long), 1e-6)
+}
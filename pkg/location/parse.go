@@ -0,0 +1,310 @@
+package location
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var ErrUnrecognizedCoordinate = errors.New("unrecognized coordinate format")
+
+// ErrAmbiguousCoordinate is returned for two plain numbers that are a valid
+// coordinate in either order, such as "-80.1 25.7".
+var ErrAmbiguousCoordinate = errors.New("coordinate is valid as latitude, longitude and as longitude, latitude")
+
+// axis is the meaning of one parsed component, if its text said.
+type axis int
+
+const (
+	axisUnknown axis = iota
+	axisLat
+	axisLong
+)
+
+// coordinateSymbols maps the many ways people type degree, minute and second
+// marks to the ones tokenizeCoordinate expects.
+var coordinateSymbols = strings.NewReplacer(
+	"º", "°", "˚", "°", "∘", "°",
+	"′", "'", "’", "'", "‘", "'", "´", "'",
+	"″", `"`, "”", `"`, "“", `"`, "''", `"`,
+)
+
+// ParseLatLong parses a coordinate written in any of the common forms:
+//
+//	38.676026,-90.377994             decimal degrees, latitude first
+//	38.676026 -90.377994
+//	38.676026N 90.377994W            hemisphere letters before or after
+//	38°40'33"N 90°22'40"W            degrees, minutes and seconds
+//	38° 40.56' N, 90° 22.68' W       degrees and decimal minutes
+//	geo:38.676026,-90.377994;u=35    RFC 5870 geo URI
+//	[-90.377994, 38.676026]          GeoJSON position, longitude first
+//	{"type": "Point", "coordinates": [-90.377994, 38.676026]}
+//
+// Hemisphere letters decide which value is which. Without them the values
+// are taken in whichever order is valid, as with "-90.377994 38.676026";
+// when both orders are, the error wraps ErrAmbiguousCoordinate and the
+// letters must be given. Geo URIs and GeoJSON fix the order themselves.
+func ParseLatLong(s string) (Coordinate, error) {
+	text := strings.TrimSpace(s)
+
+	switch {
+	case len(text) >= 4 && strings.EqualFold(text[:4], "geo:"):
+		return parseGeoURI(s, text[4:])
+	case strings.HasPrefix(text, "{"):
+		return parseGeoJSONPoint(s, text)
+	case strings.HasPrefix(text, "["):
+		return parseGeoJSONPosition(s, text)
+	}
+
+	tokens, ok := tokenizeCoordinate(coordinateSymbols.Replace(text))
+	if !ok {
+		return Coordinate{}, fmt.Errorf("%q: %w", s, ErrUnrecognizedCoordinate)
+	}
+
+	first, rest, err := parseComponent(tokens)
+	if err != nil {
+		return Coordinate{}, fmt.Errorf("%q: %w", s, err)
+	}
+
+	if len(rest) > 0 && rest[0].kind == separatorToken {
+		rest = rest[1:]
+	}
+
+	second, rest, err := parseComponent(rest)
+	if err != nil {
+		return Coordinate{}, fmt.Errorf("%q: %w", s, err)
+	}
+
+	if len(rest) > 0 {
+		return Coordinate{}, fmt.Errorf("%q: %w", s, ErrUnrecognizedCoordinate)
+	}
+
+	switch {
+	case first.axis == axisUnknown && second.axis == axisUnknown:
+		return orderedCoordinate(s, first.value, second.value)
+	case first.axis == second.axis:
+		return Coordinate{}, fmt.Errorf("%q: both values are for the same axis: %w", s, ErrUnrecognizedCoordinate)
+	case first.axis == axisLong || second.axis == axisLat:
+		first, second = second, first
+	}
+
+	c, err := NewCoordinate(first.value, second.value)
+	if err != nil {
+		return Coordinate{}, fmt.Errorf("%q: %w", s, err)
+	}
+	return c, nil
+}
+
+type tokenKind int
+
+const (
+	numberToken tokenKind = iota
+	degreesToken
+	minutesToken
+	secondsToken
+	hemisphereToken
+	separatorToken
+)
+
+type coordinateToken struct {
+	kind tokenKind
+	text string
+}
+
+// tokenizeCoordinate splits s into numbers, degree/minute/second marks,
+// hemisphere letters and separators, dropping spaces. It fails on anything
+// else.
+func tokenizeCoordinate(s string) ([]coordinateToken, bool) {
+	var tokens []coordinateToken
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(s) && (s[j] == '.' || (s[j] >= '0' && s[j] <= '9')) {
+				j++
+			}
+			tokens = append(tokens, coordinateToken{numberToken, s[i:j]})
+			i = j
+		case strings.HasPrefix(s[i:], "°"):
+			tokens = append(tokens, coordinateToken{degreesToken, "°"})
+			i += len("°")
+		case c == '\'':
+			tokens = append(tokens, coordinateToken{minutesToken, "'"})
+			i++
+		case c == '"':
+			tokens = append(tokens, coordinateToken{secondsToken, `"`})
+			i++
+		case strings.IndexByte("NSEWnsew", c) >= 0:
+			tokens = append(tokens, coordinateToken{hemisphereToken, strings.ToUpper(string(c))})
+			i++
+		case c == ',' || c == ';' || c == '/':
+			tokens = append(tokens, coordinateToken{separatorToken, string(c)})
+			i++
+		default:
+			return nil, false
+		}
+	}
+
+	return tokens, true
+}
+
+type coordinateComponent struct {
+	value float64
+	axis  axis
+}
+
+// parseComponent reads one latitude or longitude from the front of tokens:
+// an optional hemisphere letter, signed degrees with an optional mark,
+// optional minutes and seconds with their marks, and, if there was no
+// leading letter, an optional trailing one.
+func parseComponent(tokens []coordinateToken) (coordinateComponent, []coordinateToken, error) {
+	next := func(kind tokenKind) (string, bool) {
+		if len(tokens) > 0 && tokens[0].kind == kind {
+			text := tokens[0].text
+			tokens = tokens[1:]
+			return text, true
+		}
+		return "", false
+	}
+
+	// a number only counts as minutes or seconds when its mark follows it
+	marked := func(mark tokenKind) (string, bool) {
+		if len(tokens) > 1 && tokens[0].kind == numberToken && tokens[1].kind == mark {
+			text := tokens[0].text
+			tokens = tokens[2:]
+			return text, true
+		}
+		return "", false
+	}
+
+	hemisphere, leading := next(hemisphereToken)
+
+	degreesStr, ok := next(numberToken)
+	if !ok {
+		return coordinateComponent{}, nil, ErrUnrecognizedCoordinate
+	}
+	next(degreesToken)
+
+	minutesStr, _ := marked(minutesToken)
+	secondsStr, _ := marked(secondsToken)
+
+	if !leading {
+		hemisphere, _ = next(hemisphereToken)
+	}
+
+	value, err := strconv.ParseFloat(degreesStr, 64)
+	if err != nil {
+		return coordinateComponent{}, nil, ErrUnrecognizedCoordinate
+	}
+
+	negative := strings.HasPrefix(degreesStr, "-")
+	value = math.Abs(value)
+
+	// only the last of degrees, minutes and seconds may have a fraction
+	whole := value == math.Trunc(value)
+
+	for i, part := range []string{minutesStr, secondsStr} {
+		if part == "" {
+			continue
+		}
+		if !whole {
+			return coordinateComponent{}, nil, fmt.Errorf("fraction before minutes or seconds: %w", ErrUnrecognizedCoordinate)
+		}
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil || v < 0 || v >= 60 {
+			return coordinateComponent{}, nil, fmt.Errorf("minutes and seconds must be from 0 to 60: %w", ErrUnrecognizedCoordinate)
+		}
+		whole = v == math.Trunc(v)
+		value += v / math.Pow(60, float64(i+1))
+	}
+
+	a := axisUnknown
+	switch hemisphere {
+	case "N":
+		a = axisLat
+	case "S":
+		a, negative = axisLat, !negative
+	case "E":
+		a = axisLong
+	case "W":
+		a, negative = axisLong, !negative
+	}
+
+	if negative {
+		value = -value
+	}
+
+	return coordinateComponent{value: value, axis: a}, tokens, nil
+}
+
+// orderedCoordinate builds a coordinate from two values without hemisphere
+// letters, latitude first unless only the swapped order is valid. Values
+// valid either way are ambiguous, since nothing says which is the latitude.
+func orderedCoordinate(s string, lat, long float64) (Coordinate, error) {
+	c, err := NewCoordinate(lat, long)
+	swapped, swapErr := NewCoordinate(long, lat)
+
+	switch {
+	case err == nil && swapErr == nil && c != swapped:
+		return Coordinate{}, fmt.Errorf("%q: add N/S and E/W to say which is the latitude: %w", s, ErrAmbiguousCoordinate)
+	case err == nil:
+		return c, nil
+	case swapErr == nil:
+		return swapped, nil
+	}
+
+	return Coordinate{}, fmt.Errorf("%q: %w", s, err)
+}
+
+// parseGeoURI parses the part of an RFC 5870 URI after "geo:", ignoring the
+// altitude and any parameters such as uncertainty.
+func parseGeoURI(s, rest string) (Coordinate, error) {
+	if i := strings.IndexByte(rest, ';'); i >= 0 {
+		rest = rest[:i]
+	}
+
+	parts := strings.Split(rest, ",")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Coordinate{}, fmt.Errorf("%q: %w", s, ErrUnrecognizedCoordinate)
+	}
+
+	c, err := ParseCoordinate(parts[0], parts[1])
+	if err != nil {
+		return Coordinate{}, fmt.Errorf("%q: %w", s, err)
+	}
+	return c, nil
+}
+
+func parseGeoJSONPosition(s, text string) (Coordinate, error) {
+	var position []float64
+	if err := json.Unmarshal([]byte(text), &position); err != nil || len(position) < 2 {
+		return Coordinate{}, fmt.Errorf("%q: %w", s, ErrUnrecognizedCoordinate)
+	}
+	c, err := NewCoordinate(position[1], position[0])
+	if err != nil {
+		return Coordinate{}, fmt.Errorf("%q: %w", s, err)
+	}
+	return c, nil
+}
+
+func parseGeoJSONPoint(s, text string) (Coordinate, error) {
+	var point struct {
+		Type        string    `json:"type"`
+		Coordinates []float64 `json:"coordinates"`
+	}
+	if err := json.Unmarshal([]byte(text), &point); err != nil || point.Type != "Point" || len(point.Coordinates) < 2 {
+		return Coordinate{}, fmt.Errorf("%q: %w", s, ErrUnrecognizedCoordinate)
+	}
+	c, err := NewCoordinate(point.Coordinates[1], point.Coordinates[0])
+	if err != nil {
+		return Coordinate{}, fmt.Errorf("%q: %w", s, err)
+	}
+	return c, nil
+}
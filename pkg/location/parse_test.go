@@ -0,0 +1,96 @@
+package location
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseLatLong(t *testing.T) {
+
+	stLouis := Coordinate{Lat: 38.676026, Long: -90.377994}
+	dms := Coordinate{Lat: 38 + 40.0/60 + 33.0/3600, Long: -(90 + 22.0/60 + 40.0/3600)}
+
+	inputs := map[string]Coordinate{
+		"38.676026,-90.377994":                                      stLouis,
+		" 38.676026, -90.377994 ":                                   stLouis,
+		"38.676026 -90.377994":                                      stLouis,
+		"38.676026;-90.377994":                                      stLouis,
+		"38.676026N 90.377994W":                                     stLouis,
+		"38.676026 n, 90.377994 w":                                  stLouis,
+		"N38.676026 W90.377994":                                     stLouis,
+		"90.377994W 38.676026N":                                     stLouis,
+		"-90.377994 38.676026":                                      stLouis,
+		"geo:38.676026,-90.377994":                                  stLouis,
+		"GEO:38.676026,-90.377994,150;u=35":                         stLouis,
+		"[-90.377994, 38.676026]":                                   stLouis,
+		"[-90.377994, 38.676026, 150]":                              stLouis,
+		`{"type": "Point", "coordinates": [-90.377994, 38.676026]}`: stLouis,
+		`38°40'33"N 90°22'40"W`:                                     dms,
+		`38º40′33″N, 90º22′40″W`:                                    dms,
+		`38° 40' 33" N 90° 22' 40" W`:                               dms,
+		`-38°40'33" 90°22'40"`:                                      {Lat: -dms.Lat, Long: -dms.Long},
+		`38° 40.55' N, 90° 22.6' W`:                                 {Lat: 38 + 40.55/60, Long: -(90 + 22.6/60)},
+		"18.18S 66.75E":                                             {Lat: -18.18, Long: 66.75},
+	}
+
+	for input, expected := range inputs {
+		c, err := ParseLatLong(input)
+		if assert.NoError(t, err, input) {
+			assert.InDelta(t, expected.Lat, c.Lat, 1e-9, input)
+			assert.InDelta(t, expected.Long, c.Long, 1e-9, input)
+		}
+	}
+}
+
+func TestParseLatLongInvalid(t *testing.T) {
+
+	for _, input := range []string{"", "63132", "St. Louis", "38.6", "38.6N 90.3N", "NW38.6 90.3", "38°61'N 90°W", "38.5°30'N 90°W", "geo:38.6", "[1]", `{"type":"Polygon"}`} {
+		_, err := ParseLatLong(input)
+		assert.True(t, errors.Is(err, ErrUnrecognizedCoordinate), input)
+	}
+
+	_, err := ParseLatLong("95.1 -190.2")
+	assert.True(t, errors.Is(err, ErrInvalidLatitude))
+
+	_, err = ParseLatLong("geo:95.1,10")
+	assert.True(t, errors.Is(err, ErrInvalidLatitude))
+
+	// GeoJSON is always longitude first
+	_, err = ParseLatLong("[38.676026, -90.377994]")
+	assert.True(t, errors.Is(err, ErrInvalidLatitude))
+
+	// both orders are valid, so nothing says which is the latitude
+	for _, input := range []string{"-80.1 25.7", "25.7,-80.1", "40.7 -74.0"} {
+		_, err = ParseLatLong(input)
+		assert.True(t, errors.Is(err, ErrAmbiguousCoordinate), input)
+	}
+
+	c, err := ParseLatLong("80.1W 25.7N")
+	require.NoError(t, err)
+	assert.Equal(t, Coordinate{Lat: 25.7, Long: -80.1}, c)
+
+	c, err = ParseLatLong("geo:25.7,-80.1")
+	require.NoError(t, err)
+	assert.Equal(t, Coordinate{Lat: 25.7, Long: -80.1}, c)
+}
+
+func TestResolveQueryCoordinate(t *testing.T) {
+
+	idx, err := LoadZipCodeIndex("testdata/places.csv")
+	require.NoError(t, err)
+
+	res, err := ResolveQuery(idx, `38°40'33"N 90°22'40"W`)
+	require.NoError(t, err)
+
+	assert.True(t, res.FromCoordinate)
+	assert.InDelta(t, 38.6758, res.Coordinate.Lat, 1e-4)
+	assert.Equal(t, "63132", res.Zip)
+	assert.Equal(t, "Saint Louis", res.Place.City)
+	assert.Equal(t, res.Coordinate, res.Place.Coordinate)
+
+	// an ambiguous coordinate is not looked up as a place name
+	_, err = ResolveQuery(idx, "-80.1 25.7")
+	assert.True(t, errors.Is(err, ErrAmbiguousCoordinate))
+}
@@ -0,0 +1,318 @@
+package location
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+var (
+	ErrInvalidPolygon      = errors.New("invalid polygon")
+	ErrUnsupportedGeometry = errors.New("unsupported geometry")
+)
+
+// Ring is a closed line of coordinates. The last coordinate may repeat the
+// first, as GeoJSON requires, or be left for the ring to close itself.
+type Ring []Coordinate
+
+// Polygon is an outer ring followed by any holes cut out of it.
+type Polygon []Ring
+
+// Area is a set of polygons prepared for fast containment tests. A point is
+// in the area if it is in any of the polygons.
+//
+// Rings may cross the antimeridian either by jumping from 179 to -179 or by
+// running past 180; edges are always taken to be the shorter way round.
+type Area struct {
+	polygons []areaPolygon
+}
+
+type areaPolygon struct {
+	// rings are [long, lat] pairs with longitudes unwrapped so consecutive
+	// vertices are never more than 180 apart, holes shifted to line up with
+	// the outer ring
+	rings  [][][2]float64
+	bounds BoundingBox
+}
+
+// NewArea prepares polygons for containment tests. Each ring needs at least
+// three distinct vertices.
+func NewArea(polygons ...Polygon) (*Area, error) {
+	a := &Area{polygons: make([]areaPolygon, 0, len(polygons))}
+
+	for i, polygon := range polygons {
+		if len(polygon) == 0 {
+			return nil, fmt.Errorf("polygon %d has no rings: %w", i, ErrInvalidPolygon)
+		}
+
+		var prepared areaPolygon
+		for j, ring := range polygon {
+			unwrapped, err := unwrapRing(ring)
+			if err != nil {
+				return nil, fmt.Errorf("polygon %d ring %d: %w", i, j, err)
+			}
+
+			if j > 0 {
+				// line the hole up with the outer ring's longitudes
+				shift := 360 * math.Round((prepared.rings[0][0][0]-unwrapped[0][0])/360)
+				for k := range unwrapped {
+					unwrapped[k][0] += shift
+				}
+			}
+
+			prepared.rings = append(prepared.rings, unwrapped)
+		}
+
+		prepared.bounds = ringBounds(prepared.rings[0])
+		a.polygons = append(a.polygons, prepared)
+	}
+
+	return a, nil
+}
+
+func unwrapRing(ring Ring) ([][2]float64, error) {
+	if n := len(ring); n > 1 && ring[0] == ring[n-1] {
+		ring = ring[:n-1]
+	}
+	if len(ring) < 3 {
+		return nil, fmt.Errorf("%d vertices: %w", len(ring), ErrInvalidPolygon)
+	}
+
+	unwrapped := make([][2]float64, len(ring))
+	for i, c := range ring {
+		if err := c.Validate(); err != nil {
+			// a ring already unwrapped past 180 is fine
+			if !errors.Is(err, ErrInvalidLongitude) || math.Abs(c.Long) > 540 {
+				return nil, err
+			}
+		}
+
+		long := c.Long
+		if i > 0 {
+			prev := unwrapped[i-1][0]
+			long = prev + wrapLongitude(c.Long-prev)
+		}
+		unwrapped[i] = [2]float64{long, c.Lat}
+	}
+
+	return unwrapped, nil
+}
+
+// wrapLongitude maps a longitude difference into [-180, 180).
+func wrapLongitude(d float64) float64 {
+	return d - 360*math.Floor((d+180)/360)
+}
+
+func ringBounds(ring [][2]float64) BoundingBox {
+	box := BoundingBox{South: 90, North: -90}
+	west, east := math.Inf(1), math.Inf(-1)
+
+	for _, v := range ring {
+		west = math.Min(west, v[0])
+		east = math.Max(east, v[0])
+		box.South = math.Min(box.South, v[1])
+		box.North = math.Max(box.North, v[1])
+	}
+
+	if east-west >= 360 {
+		box.West, box.East = -180, 180
+		return box
+	}
+
+	box.West = wrapLongitude(west)
+	box.East = wrapLongitude(east)
+	if box.East == -180 {
+		box.East = 180
+	}
+	return box
+}
+
+// Bounds returns a bounding box for each polygon in the area.
+func (a *Area) Bounds() []BoundingBox {
+	bounds := make([]BoundingBox, 0, len(a.polygons))
+	for _, p := range a.polygons {
+		bounds = append(bounds, p.bounds)
+	}
+	return bounds
+}
+
+// Contains reports whether c is inside the area. Points exactly on an edge
+// may fall either way.
+func (a *Area) Contains(c Coordinate) bool {
+	for i := range a.polygons {
+		if a.polygons[i].contains(c) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *areaPolygon) contains(c Coordinate) bool {
+	if !p.bounds.Contains(c) {
+		return false
+	}
+
+	// the outer ring may lie anywhere from -540 to 540 once unwrapped
+	for _, shift := range []float64{0, 360, -360} {
+		long := c.Long + shift
+		if !ringContains(p.rings[0], long, c.Lat) {
+			continue
+		}
+
+		inHole := false
+		for _, hole := range p.rings[1:] {
+			if ringContains(hole, long, c.Lat) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ringContains is the even-odd ray casting test, treating longitude and
+// latitude as plane coordinates.
+func ringContains(ring [][2]float64, x, y float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// ParseArea reads the polygons from a GeoJSON Polygon, MultiPolygon,
+// GeometryCollection, Feature or FeatureCollection. Other geometry types are
+// an error wrapping ErrUnsupportedGeometry, as are collections without any
+// polygons.
+func ParseArea(data []byte) (*Area, error) {
+	polygons, err := geoJSONPolygons(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(polygons) == 0 {
+		return nil, fmt.Errorf("no polygons: %w", ErrUnsupportedGeometry)
+	}
+	return NewArea(polygons...)
+}
+
+func geoJSONPolygons(data []byte) ([]Polygon, error) {
+	var object struct {
+		Type        string            `json:"type"`
+		Coordinates json.RawMessage   `json:"coordinates"`
+		Geometry    json.RawMessage   `json:"geometry"`
+		Geometries  []json.RawMessage `json:"geometries"`
+		Features    []json.RawMessage `json:"features"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, fmt.Errorf("%v: %w", err, ErrInvalidPolygon)
+	}
+
+	switch object.Type {
+	case "Polygon":
+		var rings [][][]float64
+		if err := json.Unmarshal(object.Coordinates, &rings); err != nil {
+			return nil, fmt.Errorf("Polygon coordinates: %v: %w", err, ErrInvalidPolygon)
+		}
+		polygon, err := positionsPolygon(rings)
+		if err != nil {
+			return nil, err
+		}
+		return []Polygon{polygon}, nil
+
+	case "MultiPolygon":
+		var polygons [][][][]float64
+		if err := json.Unmarshal(object.Coordinates, &polygons); err != nil {
+			return nil, fmt.Errorf("MultiPolygon coordinates: %v: %w", err, ErrInvalidPolygon)
+		}
+		parsed := make([]Polygon, 0, len(polygons))
+		for _, rings := range polygons {
+			polygon, err := positionsPolygon(rings)
+			if err != nil {
+				return nil, err
+			}
+			parsed = append(parsed, polygon)
+		}
+		return parsed, nil
+
+	case "Feature":
+		if len(object.Geometry) == 0 || string(object.Geometry) == "null" {
+			return nil, nil
+		}
+		return geoJSONPolygons(object.Geometry)
+
+	case "GeometryCollection", "FeatureCollection":
+		members := object.Geometries
+		if object.Type == "FeatureCollection" {
+			members = object.Features
+		}
+
+		var parsed []Polygon
+		for _, member := range members {
+			polygons, err := geoJSONPolygons(member)
+			if err != nil && !errors.Is(err, ErrUnsupportedGeometry) {
+				return nil, err
+			}
+			parsed = append(parsed, polygons...)
+		}
+		return parsed, nil
+	}
+
+	return nil, fmt.Errorf("%q: %w", object.Type, ErrUnsupportedGeometry)
+}
+
+func positionsPolygon(rings [][][]float64) (Polygon, error) {
+	polygon := make(Polygon, 0, len(rings))
+	for _, positions := range rings {
+		ring := make(Ring, 0, len(positions))
+		for _, position := range positions {
+			if len(position) < 2 {
+				return nil, fmt.Errorf("position %v: %w", position, ErrInvalidPolygon)
+			}
+			ring = append(ring, Coordinate{Lat: position[1], Long: position[0]})
+		}
+		polygon = append(polygon, ring)
+	}
+	return polygon, nil
+}
+
+// InArea returns every ZIP code whose centroid is inside a, ordered by
+// country and postal code. Distance is left at zero. Only the ZIP codes in
+// each polygon's bounding box are tested against it.
+func (idx *Index) InArea(a *Area) []Match {
+	var matches []Match
+
+	// polygons may overlap, so a ZIP code found in one is not added again
+	var seen map[PostalCode]bool
+	if len(a.polygons) > 1 {
+		seen = make(map[PostalCode]bool)
+	}
+
+	for i := range a.polygons {
+		polygon := &a.polygons[i]
+		idx.visitBox(polygon.bounds, func(p indexPoint) {
+			if seen[p.key] || !polygon.contains(p.coordinate) {
+				return
+			}
+			if seen != nil {
+				seen[p.key] = true
+			}
+			matches = append(matches, Match{Country: p.key.Country, Zip: p.key.Code, Coordinate: p.coordinate})
+		})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return postalCodeLess(PostalCode{Country: matches[i].Country, Code: matches[i].Zip}, PostalCode{Country: matches[j].Country, Code: matches[j].Zip})
+	})
+
+	return matches
+}
@@ -0,0 +1,220 @@
+package location
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sort"
+	"testing"
+)
+
+func square(west, south, east, north float64) Ring {
+	return Ring{
+		{Lat: south, Long: west},
+		{Lat: south, Long: east},
+		{Lat: north, Long: east},
+		{Lat: north, Long: west},
+		{Lat: south, Long: west},
+	}
+}
+
+func TestAreaContains(t *testing.T) {
+
+	// a donut around St. Louis with the city cut out
+	a, err := NewArea(Polygon{square(-91, 38, -89, 39.5), square(-90.4, 38.5, -90.1, 38.8)})
+	require.NoError(t, err)
+
+	assert.True(t, a.Contains(Coordinate{Lat: 38.2, Long: -90.5}))
+	assert.False(t, a.Contains(Coordinate{Lat: 38.627, Long: -90.1994 - 0.1}))
+	assert.False(t, a.Contains(kansasCity))
+
+	// a second polygon around Kansas City
+	a, err = NewArea(Polygon{square(-91, 38, -89, 39.5)}, Polygon{square(-95, 38.5, -94, 39.5)})
+	require.NoError(t, err)
+	assert.True(t, a.Contains(kansasCity))
+	assert.True(t, a.Contains(stLouis))
+	assert.Len(t, a.Bounds(), 2)
+
+	// a concave L shape
+	a, err = NewArea(Polygon{{{Lat: 0, Long: 0}, {Lat: 0, Long: 2}, {Lat: 1, Long: 2}, {Lat: 1, Long: 1}, {Lat: 2, Long: 1}, {Lat: 2, Long: 0}}})
+	require.NoError(t, err)
+	assert.True(t, a.Contains(Coordinate{Lat: 1.5, Long: 0.5}))
+	assert.False(t, a.Contains(Coordinate{Lat: 1.5, Long: 1.5}))
+}
+
+func TestAreaAntimeridian(t *testing.T) {
+
+	inside := []Coordinate{{Lat: 52, Long: 175}, {Lat: 52, Long: -175}, {Lat: 52, Long: 180}}
+	outside := []Coordinate{{Lat: 52, Long: 165}, {Lat: 52, Long: -165}, {Lat: 52, Long: 0}, {Lat: 56, Long: 178}}
+
+	rings := map[string]Ring{
+		// jumping from 170 to -170
+		"jump": {{Lat: 50, Long: 170}, {Lat: 50, Long: -170}, {Lat: 55, Long: -170}, {Lat: 55, Long: 170}},
+		// running past 180
+		"past": {{Lat: 50, Long: 170}, {Lat: 50, Long: 190}, {Lat: 55, Long: 190}, {Lat: 55, Long: 170}},
+		"west": {{Lat: 50, Long: -190}, {Lat: 50, Long: -170}, {Lat: 55, Long: -170}, {Lat: 55, Long: -190}},
+	}
+
+	for name, ring := range rings {
+		t.Run(name, func(t *testing.T) {
+			// with a hole from 178 to -178 written the other way round
+			hole := Ring{{Lat: 53, Long: -178}, {Lat: 53, Long: 178}, {Lat: 54, Long: 178}, {Lat: 54, Long: -178}}
+			a, err := NewArea(Polygon{ring, hole})
+			require.NoError(t, err)
+
+			for _, c := range inside {
+				assert.True(t, a.Contains(c), c.String())
+			}
+			for _, c := range outside {
+				assert.False(t, a.Contains(c), c.String())
+			}
+			assert.False(t, a.Contains(Coordinate{Lat: 53.5, Long: 179}))
+			assert.False(t, a.Contains(Coordinate{Lat: 53.5, Long: -179}))
+
+			bounds := a.Bounds()[0]
+			assert.Equal(t, 170.0, bounds.West)
+			assert.Equal(t, -170.0, bounds.East)
+		})
+	}
+}
+
+func TestNewAreaRejectsBadRings(t *testing.T) {
+
+	_, err := NewArea(Polygon{{{Lat: 0, Long: 0}, {Lat: 1, Long: 1}, {Lat: 0, Long: 0}}})
+	assert.True(t, errors.Is(err, ErrInvalidPolygon))
+
+	_, err = NewArea(Polygon{})
+	assert.True(t, errors.Is(err, ErrInvalidPolygon))
+
+	_, err = NewArea(Polygon{square(0, 80, 1, 95)})
+	assert.True(t, errors.Is(err, ErrInvalidLatitude))
+}
+
+func TestParseArea(t *testing.T) {
+
+	a, err := ParseArea([]byte(`{
+		"type": "FeatureCollection",
+		"features": [
+			{"type": "Feature", "properties": {"event": "Tornado Warning"}, "geometry": {
+				"type": "Polygon",
+				"coordinates": [[[-91, 38], [-89, 38], [-89, 39.5], [-91, 39.5], [-91, 38]]]
+			}},
+			{"type": "Feature", "properties": {}, "geometry": {"type": "Point", "coordinates": [-94, 39]}},
+			{"type": "Feature", "properties": {}, "geometry": null},
+			{"type": "Feature", "properties": {}, "geometry": {
+				"type": "MultiPolygon",
+				"coordinates": [[[[-95, 38.5, 0], [-94, 38.5, 0], [-94, 39.5, 0], [-95, 39.5, 0], [-95, 38.5, 0]]]]
+			}}
+		]
+	}`))
+	require.NoError(t, err)
+	assert.True(t, a.Contains(stLouis))
+	assert.True(t, a.Contains(kansasCity))
+	assert.False(t, a.Contains(Coordinate{Lat: 39, Long: -92}))
+
+	_, err = ParseArea([]byte(`{"type": "Point", "coordinates": [-94, 39]}`))
+	assert.True(t, errors.Is(err, ErrUnsupportedGeometry))
+
+	_, err = ParseArea([]byte(`{"type": "Polygon", "coordinates": [[-94, 39]]}`))
+	assert.True(t, errors.Is(err, ErrInvalidPolygon))
+
+	_, err = ParseArea([]byte(`not json`))
+	assert.True(t, errors.Is(err, ErrInvalidPolygon))
+}
+
+func TestIndexInArea(t *testing.T) {
+
+	idx, err := LoadZipCodeIndex("zipdata/zip.csv")
+	require.NoError(t, err)
+
+	a, err := NewArea(Polygon{square(-90.4, 38.6, -90.3, 38.7)})
+	require.NoError(t, err)
+
+	matches := idx.InArea(a)
+	require.NotEmpty(t, matches)
+
+	zips := make([]string, 0, len(matches))
+	for _, m := range matches {
+		assert.True(t, a.Contains(m.Coordinate))
+		zips = append(zips, m.Zip)
+	}
+	assert.Contains(t, zips, "63132")
+	assert.True(t, sort.StringsAreSorted(zips))
+
+	// the same answer as checking every ZIP code in the bounding box
+	var want []string
+	for _, m := range idx.InBox(BoundingBox{South: 38.6, West: -90.4, North: 38.7, East: -90.3}) {
+		want = append(want, m.Zip)
+	}
+	assert.ElementsMatch(t, want, zips)
+}
+
+// inAreaScan is InArea the slow way, testing every ZIP code.
+func inAreaScan(idx *Index, a *Area) []Match {
+	var matches []Match
+	for _, p := range idx.points {
+		if a.Contains(p.coordinate) {
+			matches = append(matches, Match{Country: p.key.Country, Zip: p.key.Code, Coordinate: p.coordinate})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return postalCodeLess(PostalCode{Country: matches[i].Country, Code: matches[i].Zip}, PostalCode{Country: matches[j].Country, Code: matches[j].Zip})
+	})
+
+	return matches
+}
+
+func TestIndexInAreaMatchesScan(t *testing.T) {
+
+	idx, err := LoadZipCodeIndex("zipdata/zip.csv")
+	require.NoError(t, err)
+
+	areas := map[string][]Polygon{
+		"missouri":    {missouri()},
+		"two":         {{square(-91, 38, -89, 39.5)}, {square(-95, 38.5, -94, 39.5)}},
+		"overlapping": {{square(-91, 38, -89, 39.5)}, {square(-90, 38, -88, 39.5)}},
+		// the Aleutians, across the antimeridian
+		"aleutians": {{{{Lat: 50, Long: 170}, {Lat: 50, Long: -160}, {Lat: 56, Long: -160}, {Lat: 56, Long: 170}}}},
+		"hawaii":    {{square(-161, 18, -154, 23)}},
+	}
+
+	for name, polygons := range areas {
+		t.Run(name, func(t *testing.T) {
+			a, err := NewArea(polygons...)
+			require.NoError(t, err)
+
+			want := inAreaScan(idx, a)
+			require.NotEmpty(t, want)
+			assert.Equal(t, want, idx.InArea(a))
+		})
+	}
+}
+
+// missouri is roughly Missouri with a hole for the St. Louis area.
+func missouri() Polygon {
+	return Polygon{
+		{{Lat: 36, Long: -95.8}, {Lat: 36, Long: -89.1}, {Lat: 40.6, Long: -91.4}, {Lat: 40.6, Long: -95.8}},
+		square(-90.6, 38.4, -90.1, 38.9),
+	}
+}
+
+func BenchmarkIndexInArea(b *testing.B) {
+	idx, err := LoadZipCodeIndex("zipdata/zip.csv")
+	require.NoError(b, err)
+
+	a, err := NewArea(missouri())
+	require.NoError(b, err)
+
+	b.Run("tree", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = idx.InArea(a)
+		}
+	})
+
+	b.Run("scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = inAreaScan(idx, a)
+		}
+	})
+}
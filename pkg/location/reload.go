@@ -0,0 +1,207 @@
+package location
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrEmptyDataset = errors.New("dataset has no zip codes")
+
+// ErrSnapshotSource is returned by a Reloader given a snapshot. Snapshots
+// hold only US ZIP codes and coordinates, so serving one would silently drop
+// the names, counties, time zones and other countries a CSV dataset has.
+var ErrSnapshotSource = errors.New("snapshots cannot be served, use the CSV file they were built from")
+
+// ReloadStats describes the dataset currently served by a Reloader.
+type ReloadStats struct {
+	Source   string
+	Rows     int
+	LoadTime time.Duration
+	LoadedAt time.Time
+}
+
+// Reloader is a Resolver whose dataset can be replaced while it is in use.
+// Lookups always see either the old or the new dataset in full; a reload
+// that fails leaves the old dataset in place.
+type Reloader struct {
+	source string
+	open   func(source string) (*Index, error)
+
+	current atomic.Value // *reloaded
+
+	// mu serialises reloads so two slow loads cannot finish out of order.
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+}
+
+type reloaded struct {
+	index *Index
+	stats ReloadStats
+}
+
+var (
+	_ Resolver       = (*Reloader)(nil)
+	_ PrefixResolver = (*Reloader)(nil)
+	_ PlaceResolver  = (*Reloader)(nil)
+	_ PostalResolver = (*Reloader)(nil)
+	_ Searcher       = (*Reloader)(nil)
+	_ BoxResolver    = (*Reloader)(nil)
+	_ AreaResolver   = (*Reloader)(nil)
+)
+
+// NewReloader loads source with OpenIndex and returns a Reloader serving it.
+// source must be a CSV file or BuiltinSource; snapshots are rejected with
+// ErrSnapshotSource, when first loaded or when a reload finds one.
+func NewReloader(source string) (*Reloader, error) {
+	r := &Reloader{source: source, open: openFullIndex}
+
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *Reloader) Lookup(zip string) (Coordinate, error) {
+	return r.Index().Lookup(zip)
+}
+
+func (r *Reloader) Nearest(c Coordinate, k int) []Match {
+	return r.Index().Nearest(c, k)
+}
+
+func (r *Reloader) LookupPlace(zip string) (Place, error) {
+	return r.Index().LookupPlace(zip)
+}
+
+func (r *Reloader) LookupPostalCode(code PostalCode) (Place, error) {
+	return r.Index().LookupPostalCode(code)
+}
+
+func (r *Reloader) Suggest(query string, limit int) []Suggestion {
+	return r.Index().Suggest(query, limit)
+}
+
+func (r *Reloader) Exact(query string) []Suggestion {
+	return r.Index().Exact(query)
+}
+
+func (r *Reloader) PlaceNames() int {
+	return r.Index().PlaceNames()
+}
+
+func (r *Reloader) LookupPrefix(zip3 string) (Coordinate, error) {
+	return r.Index().LookupPrefix(zip3)
+}
+
+func (r *Reloader) InBox(box BoundingBox) []Match {
+	return r.Index().InBox(box)
+}
+
+func (r *Reloader) InArea(a *Area) []Match {
+	return r.Index().InArea(a)
+}
+
+// Index returns the dataset currently being served.
+func (r *Reloader) Index() *Index {
+	return r.current.Load().(*reloaded).index
+}
+
+// Stats describes the dataset currently being served.
+func (r *Reloader) Stats() ReloadStats {
+	return r.current.Load().(*reloaded).stats
+}
+
+// Reload loads the source again and, if it is valid, swaps it in. Either
+// way the file is remembered as seen, so Watch does not retry a broken file
+// every tick.
+func (r *Reloader) Reload() (ReloadStats, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// stat before reading so a write that lands mid-load, even one fixing a
+	// broken file, triggers another reload on the next poll
+	info, statErr := os.Stat(r.source)
+	if statErr == nil {
+		r.modTime, r.size = info.ModTime(), info.Size()
+	}
+
+	start := time.Now()
+	index, err := r.open(r.source)
+	if err == nil && index.Len() == 0 {
+		err = ErrEmptyDataset
+	}
+	if err != nil {
+		return ReloadStats{}, fmt.Errorf("reloading %s: %w", r.source, err)
+	}
+
+	stats := ReloadStats{
+		Source:   r.source,
+		Rows:     index.Len(),
+		LoadTime: time.Since(start),
+		LoadedAt: start,
+	}
+
+	r.current.Store(&reloaded{index: index, stats: stats})
+
+	return stats, nil
+}
+
+// openFullIndex is OpenIndex for every source but snapshots.
+func openFullIndex(source string) (*Index, error) {
+	if source != BuiltinSource && isSnapshot(source) {
+		return nil, ErrSnapshotSource
+	}
+	return OpenIndex(source)
+}
+
+// changed reports whether the source file looks different from the one last
+// loaded. Sources that are not files, like BuiltinSource, never change.
+func (r *Reloader) changed() bool {
+	info, err := os.Stat(r.source)
+	if err != nil {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return !info.ModTime().Equal(r.modTime) || info.Size() != r.size
+}
+
+// Watch polls the source file every interval and reloads it when its size or
+// modification time changes, until ctx is done. onReload, if not nil, is
+// called with the outcome of every reload attempt.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration, onReload func(ReloadStats, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	r.watch(ctx, ticker.C, onReload)
+}
+
+// watch is Watch polling on every value from ticks.
+func (r *Reloader) watch(ctx context.Context, ticks <-chan time.Time, onReload func(ReloadStats, error)) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticks:
+		}
+
+		if !r.changed() {
+			continue
+		}
+
+		stats, err := r.Reload()
+
+		if onReload != nil {
+			onReload(stats, err)
+		}
+	}
+}
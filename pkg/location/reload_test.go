@@ -0,0 +1,179 @@
+package location
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeDataset replaces filename in one step so a watcher never sees it half
+// written or with the wrong modification time.
+func writeDataset(t *testing.T, filename string, contents string, modTime time.Time) {
+	tmp := filename + ".tmp"
+	require.NoError(t, ioutil.WriteFile(tmp, []byte(contents), 0644))
+	require.NoError(t, os.Chtimes(tmp, modTime, modTime))
+	require.NoError(t, os.Rename(tmp, filename))
+}
+
+func TestReloaderKeepsOldDataOnBadReload(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "reload")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "zip.csv")
+	writeDataset(t, filename, "ZIP,LAT,LNG\n00601,18.180555,-66.749961\n", time.Now())
+
+	r, err := NewReloader(filename)
+	require.NoError(t, err)
+	assert.Equal(t, 1, r.Stats().Rows)
+
+	writeDataset(t, filename, "ZIP,LAT,LNG\n00601,98.180555,-66.749961\n", time.Now())
+
+	_, err = r.Reload()
+	assert.True(t, errors.Is(err, ErrInvalidLatitude))
+
+	writeDataset(t, filename, "ZIP,LAT,LNG\n", time.Now())
+
+	_, err = r.Reload()
+	assert.True(t, errors.Is(err, ErrEmptyDataset))
+
+	c, err := r.Lookup("00601")
+	require.NoError(t, err)
+	assert.Equal(t, 18.180555, c.Lat)
+
+	writeDataset(t, filename, "ZIP,LAT,LNG\n00601,18.180555,-66.749961\n00602,18.361945,-67.175597\n", time.Now())
+
+	stats, err := r.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Rows)
+	assert.Equal(t, filename, stats.Source)
+	assert.Equal(t, stats, r.Stats())
+
+	_, err = r.Lookup("00602")
+	assert.NoError(t, err)
+}
+
+func TestReloaderWatch(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "reload")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "zip.csv")
+	modTime := time.Now().Add(-time.Hour)
+	writeDataset(t, filename, "ZIP,LAT,LNG\n00601,18.180555,-66.749961\n", modTime)
+
+	r, err := NewReloader(filename)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// ticks is unbuffered, so each tick is taken only once the one before
+	// has been handled
+	ticks := make(chan time.Time)
+	results := make(chan error, 10)
+	go r.watch(ctx, ticks, func(stats ReloadStats, err error) {
+		results <- err
+	})
+
+	// nothing changed, nothing reloaded
+	ticks <- time.Now()
+	ticks <- time.Now()
+	assert.Len(t, results, 0)
+
+	writeDataset(t, filename, "ZIP,LAT,LNG\nbroken", modTime.Add(time.Minute))
+	ticks <- time.Now()
+	assert.Error(t, <-results)
+
+	// the broken file is not retried
+	ticks <- time.Now()
+	ticks <- time.Now()
+	assert.Len(t, results, 0)
+
+	writeDataset(t, filename, "ZIP,LAT,LNG\n00602,18.361945,-67.175597\n", modTime.Add(2*time.Minute))
+	ticks <- time.Now()
+	assert.NoError(t, <-results)
+
+	_, err = r.Lookup("00602")
+	assert.NoError(t, err)
+	_, err = r.Lookup("00601")
+	assert.True(t, errors.Is(err, ErrZipNotFound))
+
+	ticks <- time.Now()
+	ticks <- time.Now()
+	assert.Len(t, results, 0)
+}
+
+func TestReloaderSeesFixDuringFailedReload(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "reload")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "zip.csv")
+	modTime := time.Now().Add(-time.Hour)
+	writeDataset(t, filename, "ZIP,LAT,LNG\n00601,18.180555,-66.749961\n", modTime)
+
+	r, err := NewReloader(filename)
+	require.NoError(t, err)
+
+	writeDataset(t, filename, "ZIP,LAT,LNG\nbroken", modTime.Add(time.Minute))
+	assert.True(t, r.changed())
+
+	// the broken file is fixed while it is being loaded
+	r.open = func(source string) (*Index, error) {
+		writeDataset(t, filename, "ZIP,LAT,LNG\n00602,18.361945,-67.175597\n", modTime.Add(2*time.Minute))
+		return nil, ErrEmptyDataset
+	}
+
+	_, err = r.Reload()
+	assert.Error(t, err)
+	assert.True(t, r.changed())
+
+	// a failed reload of an unchanged file is not retried
+	r.open = func(source string) (*Index, error) {
+		return nil, ErrEmptyDataset
+	}
+
+	_, err = r.Reload()
+	assert.Error(t, err)
+	assert.False(t, r.changed())
+}
+
+func TestReloaderRejectsSnapshots(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "reload")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var snapshot bytes.Buffer
+	require.NoError(t, WriteSnapshot(&snapshot, map[string]Coordinate{"00601": {Lat: 18.180555, Long: -66.749961}}))
+
+	snapshotFile := filepath.Join(dir, "zip"+SnapshotExt)
+	require.NoError(t, ioutil.WriteFile(snapshotFile, snapshot.Bytes(), 0644))
+
+	_, err = NewReloader(snapshotFile)
+	assert.True(t, errors.Is(err, ErrSnapshotSource))
+
+	// nor is a snapshot swapped in for the CSV file served
+	filename := filepath.Join(dir, "zip.csv")
+	writeDataset(t, filename, "ZIP,LAT,LNG\n00601,18.180555,-66.749961\n", time.Now())
+
+	r, err := NewReloader(filename)
+	require.NoError(t, err)
+
+	writeDataset(t, filename, snapshot.String(), time.Now())
+
+	_, err = r.Reload()
+	assert.True(t, errors.Is(err, ErrSnapshotSource))
+	assert.Equal(t, 1, r.Stats().Rows)
+}
@@ -0,0 +1,151 @@
+package location
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Resolver maps ZIP codes to coordinates and coordinates back to the
+// nearest ZIP codes.
+type Resolver interface {
+	// Lookup returns the centroid of zip, or an error wrapping ErrZipNotFound.
+	Lookup(zip string) (Coordinate, error)
+
+	// Nearest returns up to k ZIP codes closest to c, nearest first.
+	Nearest(c Coordinate, k int) []Match
+}
+
+// BoxResolver is implemented by resolvers that can list every ZIP code in a
+// bounding box.
+type BoxResolver interface {
+	// InBox returns every ZIP code inside box, ordered by distance from its
+	// center.
+	InBox(box BoundingBox) []Match
+}
+
+// AreaResolver is implemented by resolvers that can list every ZIP code in
+// an Area.
+type AreaResolver interface {
+	// InArea returns every ZIP code whose centroid is inside a.
+	InArea(a *Area) []Match
+}
+
+var (
+	_ Resolver = (*Index)(nil)
+	_ Resolver = MapResolver(nil)
+	_ Resolver = (*Snapshot)(nil)
+
+	_ PrefixResolver = (*Index)(nil)
+	_ PrefixResolver = MapResolver(nil)
+	_ PrefixResolver = (*Snapshot)(nil)
+
+	_ PlaceResolver  = (*Index)(nil)
+	_ PostalResolver = (*Index)(nil)
+	_ Searcher       = (*Index)(nil)
+	_ Searcher       = (*SearchIndex)(nil)
+
+	_ BoxResolver = (*Index)(nil)
+	_ BoxResolver = MapResolver(nil)
+
+	_ AreaResolver = (*Index)(nil)
+)
+
+// BuiltinSource is the source name Open uses for the compiled-in dataset.
+const BuiltinSource = "builtin"
+
+// Open returns a resolver for source, which is either BuiltinSource, a
+// snapshot file, or a ZIP code CSV file. Snapshots are recognised by their
+// SnapshotExt extension or their contents, and are searched in place rather
+// than loaded, so opening one is quick.
+func Open(source string) (Resolver, error) {
+	if source != BuiltinSource && isSnapshot(source) {
+		return OpenSnapshot(source)
+	}
+	return OpenIndex(source)
+}
+
+// OpenIndex loads and indexes source. See Open.
+func OpenIndex(source string) (*Index, error) {
+	switch {
+	case source == BuiltinSource:
+		return Builtin()
+	case isSnapshot(source):
+		return LoadSnapshotIndex(source)
+	default:
+		return LoadZipCodeIndex(source)
+	}
+}
+
+func isSnapshot(source string) bool {
+	return strings.EqualFold(filepath.Ext(source), SnapshotExt) || isSnapshotFile(source)
+}
+
+// MapResolver is an unindexed resolver over a plain map. Nearest scans every
+// entry, so it suits small or test datasets; use NewIndex for large ones.
+type MapResolver map[string]Coordinate
+
+func (m MapResolver) Lookup(zip string) (Coordinate, error) {
+	c, ok := m[zip]
+	if !ok {
+		return Coordinate{}, notFound(zip)
+	}
+	return c, nil
+}
+
+func (m MapResolver) LookupPrefix(zip3 string) (Coordinate, error) {
+	prefixed := make(map[string]Coordinate)
+	for zip, c := range m {
+		if strings.HasPrefix(zip, zip3) {
+			prefixed[zip] = c
+		}
+	}
+
+	c, ok := prefixCentroids(prefixed)[zip3]
+	if !ok {
+		return Coordinate{}, notFound(zip3)
+	}
+	return c, nil
+}
+
+func (m MapResolver) Nearest(c Coordinate, k int) []Match {
+	if k <= 0 {
+		return nil
+	}
+
+	matches := make([]Match, 0, len(m))
+	for zip, coordinate := range m {
+		matches = append(matches, Match{
+			Country:    US,
+			Zip:        zip,
+			Coordinate: coordinate,
+			Distance:   Distance(c, coordinate),
+		})
+	}
+
+	sortMatches(matches)
+
+	if len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches
+}
+
+func (m MapResolver) InBox(box BoundingBox) []Match {
+	center := box.Center()
+
+	var matches []Match
+	for zip, coordinate := range m {
+		if box.Contains(coordinate) {
+			matches = append(matches, Match{
+				Country:    US,
+				Zip:        zip,
+				Coordinate: coordinate,
+				Distance:   Distance(center, coordinate),
+			})
+		}
+	}
+
+	sortMatches(matches)
+
+	return matches
+}
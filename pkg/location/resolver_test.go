@@ -0,0 +1,71 @@
+package location
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testResolvers(t *testing.T) map[string]Resolver {
+
+	zipCodeMap, err := LoadZipCodeMap("zipdata/zip.csv")
+	require.NoError(t, err)
+
+	csvResolver, err := Open("zipdata/zip.csv")
+	require.NoError(t, err)
+
+	builtinResolver, err := Open(BuiltinSource)
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "snapshot")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteSnapshot(&buf, zipCodeMap))
+	snapshotFile := filepath.Join(dir, "zip"+SnapshotExt)
+	require.NoError(t, ioutil.WriteFile(snapshotFile, buf.Bytes(), 0644))
+
+	snapshotResolver, err := Open(snapshotFile)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = snapshotResolver.(*Snapshot).Close() })
+
+	return map[string]Resolver{
+		"csv":      csvResolver,
+		"map":      MapResolver(zipCodeMap),
+		"builtin":  builtinResolver,
+		"snapshot": snapshotResolver,
+	}
+}
+
+func TestResolvers(t *testing.T) {
+
+	for name, r := range testResolvers(t) {
+		t.Run(name, func(t *testing.T) {
+
+			// snapshots store float32, good to about a metre
+			c, err := r.Lookup("00601")
+			require.NoError(t, err)
+			assert.InDelta(t, 18.180555, c.Lat, 1e-5)
+			assert.InDelta(t, -66.749961, c.Long, 1e-5)
+
+			_, err = r.Lookup("nope")
+			assert.True(t, errors.Is(err, ErrZipNotFound))
+
+			matches := r.Nearest(Coordinate{Lat: 38.676026, Long: -90.377994}, 2)
+			require.Len(t, matches, 2)
+			assert.Equal(t, "63132", matches[0].Zip)
+		})
+	}
+}
+
+func TestReadSnapshotRejectsGarbage(t *testing.T) {
+
+	_, err := ReadSnapshot(bytes.NewReader([]byte("ZIP,LAT,LNG\n00601,18,-66\n")))
+	assert.True(t, errors.Is(err, ErrBadSnapshot))
+}
@@ -0,0 +1,475 @@
+package location
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrPlaceNotFound  = errors.New("place not found")
+	ErrAmbiguousPlace = errors.New("ambiguous place name")
+
+	// ErrNoPlaceNames means the dataset has no city names to search, only
+	// postal codes and coordinates.
+	ErrNoPlaceNames = errors.New("dataset has no place names")
+)
+
+// Suggestion is a place matching a search query: one city and state with all
+// of its ZIP codes.
+type Suggestion struct {
+	Country Country
+	City    string
+	State   string
+
+	// Zips are the place's postal codes in ascending order.
+	Zips []string
+
+	// Coordinate is the centroid of Zips.
+	Coordinate Coordinate
+
+	// Match says how the query matched, best first: MatchExact, MatchPrefix,
+	// MatchWord or MatchFuzzy.
+	Match MatchKind
+}
+
+// MatchKind ranks how well a suggestion matched its query.
+type MatchKind int
+
+const (
+	// MatchExact is a city name equal to the query.
+	MatchExact MatchKind = iota
+
+	// MatchPrefix is a city name starting with the query.
+	MatchPrefix
+
+	// MatchWord is a city name with a later word starting with the query,
+	// like "Saint Louis" for "louis".
+	MatchWord
+
+	// MatchFuzzy is a city name within a small edit distance of the query.
+	MatchFuzzy
+)
+
+func (k MatchKind) String() string {
+	switch k {
+	case MatchExact:
+		return "exact"
+	case MatchPrefix:
+		return "prefix"
+	case MatchWord:
+		return "word"
+	case MatchFuzzy:
+		return "fuzzy"
+	}
+	return fmt.Sprintf("MatchKind(%d)", int(k))
+}
+
+// Searcher suggests places for a free text query.
+type Searcher interface {
+	Suggest(query string, limit int) []Suggestion
+
+	// Exact returns every place named exactly by query, in the order
+	// Suggest would rank them, however many there are.
+	Exact(query string) []Suggestion
+
+	// PlaceNames returns the number of named places, zero if the dataset
+	// has no city names.
+	PlaceNames() int
+}
+
+// SearchIndex is a prefix and fuzzy search index over place names. Places
+// without a city are left out.
+type SearchIndex struct {
+	// places is sorted by key, then country and state
+	places []searchPlace
+
+	// words holds every word after the first of each place's key, sorted,
+	// pointing back into places
+	words []searchWord
+}
+
+type searchPlace struct {
+	key        string
+	suggestion Suggestion
+}
+
+type searchWord struct {
+	word  string
+	place int
+}
+
+// NewSearchIndex groups places by city and state and indexes their names.
+func NewSearchIndex(places map[PostalCode]Place) *SearchIndex {
+	type groupID struct {
+		key     string
+		country Country
+		state   string
+	}
+
+	type group struct {
+		city string
+		zips []string
+		sum  [3]float64
+	}
+
+	groups := make(map[groupID]*group)
+
+	for key, place := range places {
+		if place.City == "" {
+			continue
+		}
+
+		id := groupID{key: foldName(place.City), country: key.Country, state: place.State}
+		g, ok := groups[id]
+		if !ok {
+			g = &group{city: place.City}
+			groups[id] = g
+		}
+
+		g.zips = append(g.zips, key.Code)
+		v := toVector(place.Coordinate)
+		g.sum = [3]float64{g.sum[0] + v[0], g.sum[1] + v[1], g.sum[2] + v[2]}
+	}
+
+	s := &SearchIndex{}
+
+	for id, g := range groups {
+		sort.Strings(g.zips)
+		s.places = append(s.places, searchPlace{
+			key: id.key,
+			suggestion: Suggestion{
+				Country:    id.country,
+				City:       g.city,
+				State:      id.state,
+				Zips:       g.zips,
+				Coordinate: fromVector(g.sum),
+			},
+		})
+	}
+
+	sort.Slice(s.places, func(i, j int) bool {
+		a, b := s.places[i], s.places[j]
+		if a.key != b.key {
+			return a.key < b.key
+		}
+		if a.suggestion.Country != b.suggestion.Country {
+			return a.suggestion.Country < b.suggestion.Country
+		}
+		return a.suggestion.State < b.suggestion.State
+	})
+
+	for i, p := range s.places {
+		words := strings.Fields(p.key)
+		for w := 1; w < len(words); w++ {
+			s.words = append(s.words, searchWord{word: strings.Join(words[w:], " "), place: i})
+		}
+	}
+
+	sort.Slice(s.words, func(i, j int) bool {
+		return s.words[i].word < s.words[j].word
+	})
+
+	return s
+}
+
+// Len returns the number of distinct city and state pairs in the index.
+func (s *SearchIndex) Len() int {
+	return len(s.places)
+}
+
+// PlaceNames is Len, for Searcher.
+func (s *SearchIndex) PlaceNames() int {
+	return s.Len()
+}
+
+// Suggest returns up to limit places matching query, best first. The query
+// is a city name or the start of one, optionally followed by a comma and a
+// state code, e.g. "spring" or "Springfield, IL". Case, accents and
+// punctuation are ignored.
+//
+// Places are ranked by how they matched (see MatchKind), then by number of
+// ZIP codes as a rough measure of size, then alphabetically.
+func (s *SearchIndex) Suggest(query string, limit int) []Suggestion {
+	name, state := splitPlaceQuery(query)
+	if name == "" || limit <= 0 {
+		return nil
+	}
+
+	best := make(map[int]MatchKind)
+	consider := func(i int, kind MatchKind) {
+		if state != "" && s.places[i].suggestion.State != state {
+			return
+		}
+		if prev, ok := best[i]; !ok || kind < prev {
+			best[i] = kind
+		}
+	}
+
+	start := sort.Search(len(s.places), func(i int) bool {
+		return s.places[i].key >= name
+	})
+	for i := start; i < len(s.places) && strings.HasPrefix(s.places[i].key, name); i++ {
+		if s.places[i].key == name {
+			consider(i, MatchExact)
+		} else {
+			consider(i, MatchPrefix)
+		}
+	}
+
+	start = sort.Search(len(s.words), func(i int) bool {
+		return s.words[i].word >= name
+	})
+	for i := start; i < len(s.words) && strings.HasPrefix(s.words[i].word, name); i++ {
+		consider(s.words[i].place, MatchWord)
+	}
+
+	// only go fuzzy when the query found nothing better, typos are
+	// the common case there and it keeps type-ahead fast
+	if len(best) < limit {
+		if maxEdits := fuzzyEdits(name); maxEdits > 0 {
+			for i, p := range s.places {
+				if _, ok := best[i]; ok {
+					continue
+				}
+				if prefixDistance(name, p.key, maxEdits) <= maxEdits {
+					consider(i, MatchFuzzy)
+				}
+			}
+		}
+	}
+
+	indexes := make([]int, 0, len(best))
+	for i := range best {
+		indexes = append(indexes, i)
+	}
+
+	sort.Slice(indexes, func(a, b int) bool {
+		i, j := indexes[a], indexes[b]
+		if best[i] != best[j] {
+			return best[i] < best[j]
+		}
+		if len(s.places[i].suggestion.Zips) != len(s.places[j].suggestion.Zips) {
+			return len(s.places[i].suggestion.Zips) > len(s.places[j].suggestion.Zips)
+		}
+		return i < j
+	})
+
+	if len(indexes) > limit {
+		indexes = indexes[:limit]
+	}
+
+	suggestions := make([]Suggestion, len(indexes))
+	for n, i := range indexes {
+		suggestions[n] = s.places[i].suggestion
+		suggestions[n].Match = best[i]
+	}
+
+	return suggestions
+}
+
+// FindPlace returns the single place named by query, which must name a city
+// exactly, e.g. "Springfield, IL". If the city exists in several states and
+// query does not say which, the error wraps ErrAmbiguousPlace and lists them.
+// If s has no place names at all the error wraps ErrNoPlaceNames.
+func FindPlace(s Searcher, query string) (Suggestion, error) {
+	query = strings.TrimSpace(query)
+
+	if s.PlaceNames() == 0 {
+		return Suggestion{}, fmt.Errorf("%q: %w", query, ErrNoPlaceNames)
+	}
+
+	exact := s.Exact(query)
+
+	switch len(exact) {
+	case 0:
+		return Suggestion{}, fmt.Errorf("%q: %w", query, ErrPlaceNotFound)
+	case 1:
+		return exact[0], nil
+	}
+
+	names := make([]string, len(exact))
+	for i, suggestion := range exact {
+		names[i] = suggestion.City + ", " + suggestion.State
+	}
+
+	return Suggestion{}, fmt.Errorf("%q could be %s: %w", query, strings.Join(names, "; "), ErrAmbiguousPlace)
+}
+
+// ResolveQuery resolves input as a ZIP code with Resolve, as a coordinate
+// in any form ParseLatLong accepts, or as a place name with FindPlace. A
+// place resolves to the centroid of its ZIP codes and reports the first of
+// them as Zip. If r is not a Searcher the error wraps ErrNoPlaceNames.
+func ResolveQuery(r Resolver, input string) (Resolution, error) {
+	if _, err := NormalizeZip(input); err == nil {
+		return Resolve(r, input)
+	}
+
+	if c, err := ParseLatLong(input); err == nil {
+		return ResolveCoordinate(r, c), nil
+	} else if errors.Is(err, ErrAmbiguousCoordinate) {
+		return Resolution{}, err
+	}
+
+	// snapshots and plain ZIP code maps carry no place names
+	searcher, ok := r.(Searcher)
+	if !ok {
+		return Resolution{}, fmt.Errorf("%q: %w", strings.TrimSpace(input), ErrNoPlaceNames)
+	}
+
+	suggestion, err := FindPlace(searcher, input)
+	if err != nil {
+		return Resolution{}, err
+	}
+
+	zip := suggestion.Zips[0]
+	place := Place{Country: suggestion.Country, Zip: zip, City: suggestion.City, State: suggestion.State}
+
+	if pr, ok := r.(PostalResolver); ok {
+		if p, err := pr.LookupPostalCode(place.PostalCode()); err == nil {
+			place = p
+		}
+	}
+	place.Coordinate = suggestion.Coordinate
+
+	return Resolution{Zip: zip, Coordinate: suggestion.Coordinate, Place: place}, nil
+}
+
+// Exact returns every place whose city is exactly the name in query, and in
+// its state if query gives one, with the most ZIP codes first.
+func (s *SearchIndex) Exact(query string) []Suggestion {
+	name, state := splitPlaceQuery(query)
+	if name == "" {
+		return nil
+	}
+
+	start := sort.Search(len(s.places), func(i int) bool {
+		return s.places[i].key >= name
+	})
+
+	var exact []Suggestion
+	for i := start; i < len(s.places) && s.places[i].key == name; i++ {
+		if state != "" && s.places[i].suggestion.State != state {
+			continue
+		}
+		suggestion := s.places[i].suggestion
+		suggestion.Match = MatchExact
+		exact = append(exact, suggestion)
+	}
+
+	sort.SliceStable(exact, func(i, j int) bool {
+		return len(exact[i].Zips) > len(exact[j].Zips)
+	})
+
+	return exact
+}
+
+// splitPlaceQuery splits "Springfield, IL" into its folded name and upper
+// case state.
+func splitPlaceQuery(query string) (name, state string) {
+	if i := strings.LastIndex(query, ","); i >= 0 {
+		query, state = query[:i], strings.ToUpper(strings.TrimSpace(query[i+1:]))
+	}
+	return foldName(query), state
+}
+
+// foldName lower cases s, strips accents and punctuation and collapses runs
+// of spaces, so "Mayagüez" matches "mayaguez" and "St. Louis" matches
+// "st louis".
+func foldName(s string) string {
+	var b strings.Builder
+	space := false
+
+	for _, r := range strings.ToLower(s) {
+		if folded, ok := accentFolds[r]; ok {
+			r = folded
+		}
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteRune(r)
+		case r == '\'' || r == '.':
+			// "O'Fallon" and "St." fold to "ofallon" and "st"
+		default:
+			space = true
+		}
+	}
+
+	return b.String()
+}
+
+var accentFolds = map[rune]rune{
+	'á': 'a', 'à': 'a', 'â': 'a', 'ä': 'a', 'ã': 'a', 'å': 'a',
+	'é': 'e', 'è': 'e', 'ê': 'e', 'ë': 'e',
+	'í': 'i', 'ì': 'i', 'î': 'i', 'ï': 'i',
+	'ó': 'o', 'ò': 'o', 'ô': 'o', 'ö': 'o', 'õ': 'o',
+	'ú': 'u', 'ù': 'u', 'û': 'u', 'ü': 'u',
+	'ñ': 'n', 'ç': 'c', 'ÿ': 'y',
+}
+
+// fuzzyEdits is how many typos a query of this length may contain.
+func fuzzyEdits(query string) int {
+	switch n := len([]rune(query)); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// prefixDistance returns the smallest edit distance between query and any
+// prefix of name, or a value above max once it is certain to exceed max.
+func prefixDistance(query, name string, max int) int {
+	q, n := []rune(query), []rune(name)
+
+	// prev[j] is the distance between the query so far and n[:j]
+	prev := make([]int, len(n)+1)
+	cur := make([]int, len(n)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(q); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(n); j++ {
+			cost := 1
+			if q[i-1] == n[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if cur[j] < rowMin {
+				rowMin = cur[j]
+			}
+		}
+		if rowMin > max {
+			return rowMin
+		}
+		prev, cur = cur, prev
+	}
+
+	best := prev[0]
+	for _, d := range prev {
+		if d < best {
+			best = d
+		}
+	}
+	return best
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
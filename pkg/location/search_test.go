@@ -0,0 +1,131 @@
+package location
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func loadSearchIndex(t *testing.T) *Index {
+	idx, err := LoadZipCodeIndex("testdata/places.csv")
+	require.NoError(t, err)
+	return idx
+}
+
+func suggestionNames(suggestions []Suggestion) []string {
+	var names []string
+	for _, s := range suggestions {
+		names = append(names, s.City+", "+s.State)
+	}
+	return names
+}
+
+func TestSuggestPrefix(t *testing.T) {
+
+	idx := loadSearchIndex(t)
+
+	suggestions := idx.Suggest("spring", 10)
+
+	assert.Equal(t, []string{"Spring, TX", "Springfield, IL", "Springfield, MO", "Springfield, MA"}, suggestionNames(suggestions))
+	assert.Equal(t, MatchExact, suggestions[0].Match)
+	assert.Equal(t, MatchPrefix, suggestions[1].Match)
+	assert.Equal(t, []string{"62701", "62702", "62703"}, suggestions[1].Zips)
+	assert.InDelta(t, 39.79, suggestions[1].Coordinate.Lat, 0.01)
+
+	assert.Len(t, idx.Suggest("spring", 2), 2)
+	assert.Equal(t, []string{"Springfield, MO"}, suggestionNames(idx.Suggest("springfield, mo", 10)))
+}
+
+func TestSuggestFolding(t *testing.T) {
+
+	idx := loadSearchIndex(t)
+
+	assert.Equal(t, []string{"Mayagüez, PR"}, suggestionNames(idx.Suggest("MAYAGUEZ", 10)))
+	assert.Equal(t, []string{"O'Fallon, MO"}, suggestionNames(idx.Suggest("ofal", 10)))
+
+	suggestions := idx.Suggest("louis", 10)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, "Saint Louis", suggestions[0].City)
+	assert.Equal(t, MatchWord, suggestions[0].Match)
+}
+
+func TestSuggestFuzzy(t *testing.T) {
+
+	idx := loadSearchIndex(t)
+
+	suggestions := idx.Suggest("sprnigfield", 10)
+
+	assert.Equal(t, []string{"Springfield, IL", "Springfield, MO", "Springfield, MA"}, suggestionNames(suggestions))
+	assert.Equal(t, MatchFuzzy, suggestions[0].Match)
+
+	assert.Empty(t, idx.Suggest("xyz", 10))
+	assert.Empty(t, idx.Suggest("", 10))
+}
+
+func TestResolveQuery(t *testing.T) {
+
+	idx := loadSearchIndex(t)
+
+	res, err := ResolveQuery(idx, "Springfield, MO")
+	require.NoError(t, err)
+	assert.Equal(t, "65801", res.Zip)
+	assert.Equal(t, "Springfield", res.Place.City)
+	assert.Equal(t, "America/Chicago", res.Place.TimeZone)
+
+	res, err = ResolveQuery(idx, "63132")
+	require.NoError(t, err)
+	assert.Equal(t, "Saint Louis", res.Place.City)
+
+	_, err = ResolveQuery(idx, "Springfield")
+	assert.True(t, errors.Is(err, ErrAmbiguousPlace))
+	assert.Contains(t, err.Error(), "Springfield, IL; Springfield, MO; Springfield, MA")
+
+	_, err = ResolveQuery(idx, "Springf")
+	assert.True(t, errors.Is(err, ErrPlaceNotFound))
+
+	unnamed, err := LoadZipCodeIndex("zipdata/zip.csv")
+	require.NoError(t, err)
+	assert.Equal(t, 0, unnamed.PlaceNames())
+
+	_, err = ResolveQuery(unnamed, "Springfield, IL")
+	assert.True(t, errors.Is(err, ErrNoPlaceNames))
+}
+
+func TestFindPlaceIsNotCapped(t *testing.T) {
+
+	// more same-named cities than Suggest would usually be asked for
+	places := make(map[PostalCode]Place)
+	for i := 0; i < 30; i++ {
+		zip := fmt.Sprintf("%05d", 10000+i)
+		places[PostalCode{Country: US, Code: zip}] = Place{
+			Country:    US,
+			Zip:        zip,
+			City:       "Franklin",
+			State:      fmt.Sprintf("T%02d", i),
+			Coordinate: Coordinate{Lat: 30 + float64(i)/10, Long: -90},
+		}
+	}
+	idx := NewPlaceIndex(places)
+
+	assert.Len(t, idx.Exact("franklin"), 30)
+	assert.Len(t, idx.Exact("Franklin, T02"), 1)
+
+	_, err := FindPlace(idx, "Franklin")
+	assert.True(t, errors.Is(err, ErrAmbiguousPlace))
+	assert.Contains(t, err.Error(), "Franklin, T00")
+	assert.Contains(t, err.Error(), "Franklin, T29")
+
+	suggestion, err := FindPlace(idx, "Franklin, T02")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10002"}, suggestion.Zips)
+}
+
+func TestPrefixDistance(t *testing.T) {
+
+	assert.Equal(t, 0, prefixDistance("spring", "springfield", 2))
+	assert.Equal(t, 1, prefixDistance("sprung", "springfield", 2))
+	assert.Equal(t, 2, prefixDistance("sprnigf", "springfield", 2))
+	assert.True(t, prefixDistance("boston", "springfield", 2) > 2)
+}
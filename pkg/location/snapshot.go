@@ -0,0 +1,283 @@
+package location
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// SnapshotExt is the file extension for binary ZIP code snapshots.
+const SnapshotExt = ".zsnap"
+
+// A snapshot is a 16 byte header followed by fixed-width records sorted by
+// ZIP code, all little endian:
+//
+//	header: "ZSNP" | version uint16 | record size uint16 | count uint32 | reserved uint32
+//	record: zip [5]byte | lat float32 | long float32
+//
+// Records need no parsing, so a snapshot can be searched in place, straight
+// from a memory mapped file.
+const (
+	snapshotMagic      = "ZSNP"
+	snapshotVersion    = 2
+	snapshotHeaderLen  = 16
+	snapshotZipLen     = 5
+	snapshotRecordSize = snapshotZipLen + 8
+)
+
+var ErrBadSnapshot = errors.New("not a zip code snapshot")
+
+// WriteSnapshot writes zipCodeMap to w in the binary snapshot format.
+// Coordinates are stored as float32, good to about a metre.
+func WriteSnapshot(w io.Writer, zipCodeMap map[string]Coordinate) error {
+	zips := make([]string, 0, len(zipCodeMap))
+	for zip := range zipCodeMap {
+		if len(zip) != snapshotZipLen {
+			return fmt.Errorf("zip %q is not %d characters", zip, snapshotZipLen)
+		}
+		zips = append(zips, zip)
+	}
+	sort.Strings(zips)
+
+	bw := bufio.NewWriter(w)
+
+	header := make([]byte, snapshotHeaderLen)
+	copy(header, snapshotMagic)
+	binary.LittleEndian.PutUint16(header[4:], snapshotVersion)
+	binary.LittleEndian.PutUint16(header[6:], snapshotRecordSize)
+	binary.LittleEndian.PutUint32(header[8:], uint32(len(zips)))
+
+	if _, err := bw.Write(header); err != nil {
+		return err
+	}
+
+	record := make([]byte, snapshotRecordSize)
+	for _, zip := range zips {
+		c := zipCodeMap[zip]
+		copy(record, zip)
+		binary.LittleEndian.PutUint32(record[snapshotZipLen:], math.Float32bits(float32(c.Lat)))
+		binary.LittleEndian.PutUint32(record[snapshotZipLen+4:], math.Float32bits(float32(c.Long)))
+		if _, err := bw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// ReadSnapshot reads a snapshot written by WriteSnapshot into a map.
+func ReadSnapshot(r io.Reader) (map[string]Coordinate, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := NewSnapshot(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.ZipCodes()
+}
+
+// LoadSnapshotIndex reads a snapshot file and indexes it.
+func LoadSnapshotIndex(filename string) (*Index, error) {
+	s, err := OpenSnapshot(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	zipCodeMap, err := s.ZipCodes()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	return NewIndex(zipCodeMap), nil
+}
+
+// Snapshot is a resolver reading a snapshot in place. Lookup is a binary
+// search over the records; the first Nearest or LookupPrefix call builds an
+// Index from them.
+type Snapshot struct {
+	data  []byte
+	count int
+	close func() error
+
+	indexOnce sync.Once
+	index     *Index
+	indexErr  error
+}
+
+// NewSnapshot checks the header of data and returns a Snapshot reading it.
+// data is used without copying and must not change while the Snapshot is in
+// use.
+func NewSnapshot(data []byte) (*Snapshot, error) {
+	if len(data) < snapshotHeaderLen || string(data[:4]) != snapshotMagic {
+		return nil, ErrBadSnapshot
+	}
+
+	if version := binary.LittleEndian.Uint16(data[4:]); version != snapshotVersion {
+		return nil, fmt.Errorf("version %d, want %d, rebuild it with zipsnap: %w", version, snapshotVersion, ErrBadSnapshot)
+	}
+	if size := binary.LittleEndian.Uint16(data[6:]); size != snapshotRecordSize {
+		return nil, fmt.Errorf("record size %d: %w", size, ErrBadSnapshot)
+	}
+
+	count := int(binary.LittleEndian.Uint32(data[8:]))
+	if want := snapshotHeaderLen + count*snapshotRecordSize; len(data) != want {
+		return nil, fmt.Errorf("%d bytes for %d records, want %d: %w", len(data), count, want, ErrBadSnapshot)
+	}
+
+	return &Snapshot{data: data, count: count}, nil
+}
+
+// OpenSnapshot opens a snapshot file, memory mapping it where the platform
+// allows. Close releases it.
+func OpenSnapshot(filename string) (*Snapshot, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, unmap, err := mapFile(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	s, err := NewSnapshot(data)
+	if err != nil {
+		_ = unmap()
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	s.close = unmap
+	return s, nil
+}
+
+// Close releases the file behind a Snapshot from OpenSnapshot. The Snapshot
+// must not be used afterwards, though an Index it built stays valid.
+func (s *Snapshot) Close() error {
+	if s.close == nil {
+		return nil
+	}
+	err := s.close()
+	s.close, s.data = nil, nil
+	return err
+}
+
+// Len returns the number of ZIP codes in the snapshot.
+func (s *Snapshot) Len() int {
+	return s.count
+}
+
+func (s *Snapshot) record(i int) []byte {
+	offset := snapshotHeaderLen + i*snapshotRecordSize
+	return s.data[offset : offset+snapshotRecordSize]
+}
+
+// At returns the i'th ZIP code in order and its centroid.
+func (s *Snapshot) At(i int) (string, Coordinate, error) {
+	record := s.record(i)
+	zip := string(record[:snapshotZipLen])
+
+	c, err := NewCoordinate(
+		float32Degrees(binary.LittleEndian.Uint32(record[snapshotZipLen:])),
+		float32Degrees(binary.LittleEndian.Uint32(record[snapshotZipLen+4:])),
+	)
+	if err != nil {
+		return zip, Coordinate{}, fmt.Errorf("record %d zip %q: %w", i, zip, err)
+	}
+	return zip, c, nil
+}
+
+// float32Degrees widens a stored coordinate by way of its shortest decimal
+// form, so 38.676026 reads back as 38.676026 rather than 38.67602539.
+func float32Degrees(bits uint32) float64 {
+	v := math.Float32frombits(bits)
+	widened, _ := strconv.ParseFloat(strconv.FormatFloat(float64(v), 'g', -1, 32), 64)
+	return widened
+}
+
+// Lookup returns the centroid of zip.
+func (s *Snapshot) Lookup(zip string) (Coordinate, error) {
+	i := sort.Search(s.count, func(i int) bool {
+		return string(s.record(i)[:snapshotZipLen]) >= zip
+	})
+
+	if i == s.count || string(s.record(i)[:snapshotZipLen]) != zip {
+		return Coordinate{}, notFound(zip)
+	}
+
+	_, c, err := s.At(i)
+	return c, err
+}
+
+// ZipCodes returns every record in the snapshot as a map.
+func (s *Snapshot) ZipCodes() (map[string]Coordinate, error) {
+	zipCodeMap := make(map[string]Coordinate, s.count)
+	for i := 0; i < s.count; i++ {
+		zip, c, err := s.At(i)
+		if err != nil {
+			return nil, err
+		}
+		zipCodeMap[zip] = c
+	}
+	return zipCodeMap, nil
+}
+
+// Index returns an Index over the snapshot, building it on first use.
+func (s *Snapshot) Index() (*Index, error) {
+	s.indexOnce.Do(func() {
+		zipCodeMap, err := s.ZipCodes()
+		if err != nil {
+			s.indexErr = err
+			return
+		}
+		s.index = NewIndex(zipCodeMap)
+	})
+	return s.index, s.indexErr
+}
+
+// Nearest returns up to k ZIP codes closest to c, nearest first.
+func (s *Snapshot) Nearest(c Coordinate, k int) []Match {
+	idx, err := s.Index()
+	if err != nil {
+		return nil
+	}
+	return idx.Nearest(c, k)
+}
+
+// LookupPrefix returns the centroid of the ZIP codes starting with zip3.
+func (s *Snapshot) LookupPrefix(zip3 string) (Coordinate, error) {
+	idx, err := s.Index()
+	if err != nil {
+		return Coordinate{}, err
+	}
+	return idx.LookupPrefix(zip3)
+}
+
+// isSnapshotFile reports whether filename starts with the snapshot magic
+// bytes, whatever its extension.
+func isSnapshotFile(filename string) bool {
+	f, err := os.Open(filename)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return false
+	}
+	return string(magic) == snapshotMagic
+}
@@ -0,0 +1,172 @@
+package location
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestSnapshot(tb testing.TB, zipCodeMap map[string]Coordinate) string {
+	dir, err := ioutil.TempDir("", "snapshot")
+	require.NoError(tb, err)
+	tb.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	var buf bytes.Buffer
+	require.NoError(tb, WriteSnapshot(&buf, zipCodeMap))
+
+	// no extension, so Open has to recognise it by its contents
+	filename := filepath.Join(dir, "zips")
+	require.NoError(tb, ioutil.WriteFile(filename, buf.Bytes(), 0644))
+	return filename
+}
+
+func TestSnapshot(t *testing.T) {
+
+	zipCodeMap := map[string]Coordinate{
+		"63132": {Lat: 38.676026, Long: -90.377994},
+		"00601": {Lat: 18.180555, Long: -66.749961},
+		"99950": {Lat: 55.542007, Long: -131.432682},
+	}
+
+	filename := writeTestSnapshot(t, zipCodeMap)
+
+	info, err := os.Stat(filename)
+	require.NoError(t, err)
+	assert.Equal(t, int64(16+3*13), info.Size())
+
+	r, err := Open(filename)
+	require.NoError(t, err)
+	s, ok := r.(*Snapshot)
+	require.True(t, ok)
+	defer s.Close()
+
+	assert.Equal(t, 3, s.Len())
+
+	zip, c, err := s.At(0)
+	require.NoError(t, err)
+	assert.Equal(t, "00601", zip)
+	assert.Equal(t, "18.180555,-66.74996", c.String())
+
+	for zip, want := range zipCodeMap {
+		c, err := s.Lookup(zip)
+		require.NoError(t, err)
+		assert.InDelta(t, want.Lat, c.Lat, 1e-5)
+		assert.InDelta(t, want.Long, c.Long, 1e-5)
+	}
+
+	for _, zip := range []string{"00000", "63133", "99999", ""} {
+		_, err := s.Lookup(zip)
+		assert.True(t, errors.Is(err, ErrZipNotFound), zip)
+	}
+
+	matches := s.Nearest(Coordinate{Lat: 38.6, Long: -90.2}, 1)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "63132", matches[0].Zip)
+
+	// snapshots say why they cannot answer what they do not hold
+	_, err = ResolvePostalCode(s, CA, "K1A 0B1")
+	assert.True(t, errors.Is(err, ErrUnknownCountry))
+
+	_, err = ResolveQuery(s, "Springfield, IL")
+	assert.True(t, errors.Is(err, ErrNoPlaceNames))
+
+	res, err := ResolveQuery(s, "63132")
+	require.NoError(t, err)
+	assert.Equal(t, "63132", res.Zip)
+
+	idx, err := OpenIndex(filename)
+	require.NoError(t, err)
+	assert.Equal(t, 3, idx.Len())
+}
+
+func TestNewSnapshotRejectsBadData(t *testing.T) {
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteSnapshot(&buf, map[string]Coordinate{"63132": {Lat: 38.676026, Long: -90.377994}}))
+	good := buf.Bytes()
+
+	_, err := NewSnapshot(good)
+	require.NoError(t, err)
+
+	truncated := good[:len(good)-1]
+	_, err = NewSnapshot(truncated)
+	assert.True(t, errors.Is(err, ErrBadSnapshot))
+
+	oldVersion := append([]byte(nil), good...)
+	binary.LittleEndian.PutUint16(oldVersion[4:], 1)
+	_, err = NewSnapshot(oldVersion)
+	assert.True(t, errors.Is(err, ErrBadSnapshot))
+	assert.Contains(t, err.Error(), "version 1")
+
+	_, err = NewSnapshot(nil)
+	assert.True(t, errors.Is(err, ErrBadSnapshot))
+}
+
+func BenchmarkLoadZipCodeMap(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := LoadZipCodeMap("zipdata/zip.csv"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLoadSnapshotMap(b *testing.B) {
+	zipCodeMap, err := LoadZipCodeMap("zipdata/zip.csv")
+	require.NoError(b, err)
+	filename := writeTestSnapshot(b, zipCodeMap)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s, err := OpenSnapshot(filename)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := s.ZipCodes(); err != nil {
+			b.Fatal(err)
+		}
+		_ = s.Close()
+	}
+}
+
+// BenchmarkOpenSnapshot is startup for a command that only looks ZIP codes
+// up: open the file and find one.
+func BenchmarkOpenSnapshot(b *testing.B) {
+	zipCodeMap, err := LoadZipCodeMap("zipdata/zip.csv")
+	require.NoError(b, err)
+	filename := writeTestSnapshot(b, zipCodeMap)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s, err := OpenSnapshot(filename)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := s.Lookup("63132"); err != nil {
+			b.Fatal(err)
+		}
+		_ = s.Close()
+	}
+}
+
+func BenchmarkSnapshotLookup(b *testing.B) {
+	zipCodeMap, err := LoadZipCodeMap("zipdata/zip.csv")
+	require.NoError(b, err)
+	filename := writeTestSnapshot(b, zipCodeMap)
+
+	s, err := OpenSnapshot(filename)
+	require.NoError(b, err)
+	defer s.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.Lookup("63132"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
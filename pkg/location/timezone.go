@@ -0,0 +1,138 @@
+package location
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var ErrUnknownTimeZone = errors.New("time zone unknown")
+
+// maxNearestDistance is how far, in miles, the nearest ZIP code may be from
+// a coordinate before it is no longer taken to describe it, as for points
+// at sea or outside the dataset's coverage.
+const maxNearestDistance = 150
+
+// zip3TimeZone maps an inclusive range of ZIP3 prefixes to the IANA time zone
+// covering most of its area. Prefixes that straddle a zone boundary take the
+// zone of their main post office, so places near a boundary can be off by an
+// hour unless the dataset carries its own time zone column.
+type zip3TimeZone struct {
+	from, to string
+	zone     string
+}
+
+// zip3TimeZones is sorted by prefix and has no overlaps.
+var zip3TimeZones = []zip3TimeZone{
+	{"005", "005", "America/New_York"},
+	{"006", "007", "America/Puerto_Rico"},
+	{"008", "008", "America/St_Thomas"},
+	{"009", "009", "America/Puerto_Rico"},
+	{"010", "089", "America/New_York"},
+	{"100", "323", "America/New_York"},
+	{"324", "325", "America/Chicago"},
+	{"326", "349", "America/New_York"},
+	{"350", "369", "America/Chicago"},
+	{"370", "372", "America/Chicago"},
+	{"373", "379", "America/New_York"},
+	{"380", "397", "America/Chicago"},
+	{"398", "399", "America/New_York"},
+	{"400", "418", "America/New_York"},
+	{"420", "424", "America/Chicago"},
+	{"425", "427", "America/New_York"},
+	{"430", "459", "America/New_York"},
+	{"460", "462", "America/Indiana/Indianapolis"},
+	{"463", "464", "America/Chicago"},
+	{"465", "475", "America/Indiana/Indianapolis"},
+	{"476", "477", "America/Chicago"},
+	{"478", "479", "America/Indiana/Indianapolis"},
+	{"480", "499", "America/Detroit"},
+	{"500", "576", "America/Chicago"},
+	{"577", "577", "America/Denver"},
+	{"580", "585", "America/Chicago"},
+	{"586", "586", "America/Denver"},
+	{"587", "588", "America/Chicago"},
+	{"590", "599", "America/Denver"},
+	{"600", "692", "America/Chicago"},
+	{"693", "693", "America/Denver"},
+	{"700", "797", "America/Chicago"},
+	{"798", "831", "America/Denver"},
+	{"832", "834", "America/Boise"},
+	{"835", "835", "America/Los_Angeles"},
+	{"836", "837", "America/Boise"},
+	{"838", "838", "America/Los_Angeles"},
+	{"840", "847", "America/Denver"},
+	{"850", "864", "America/Phoenix"},
+	{"865", "885", "America/Denver"},
+	{"889", "961", "America/Los_Angeles"},
+	{"967", "968", "Pacific/Honolulu"},
+	{"969", "969", "Pacific/Guam"},
+	{"970", "978", "America/Los_Angeles"},
+	{"979", "979", "America/Boise"},
+	{"980", "994", "America/Los_Angeles"},
+	{"995", "997", "America/Anchorage"},
+	{"998", "999", "America/Juneau"},
+}
+
+// ZipTimeZone returns the IANA time zone for a five digit ZIP code from the
+// table bundled with this package. It needs no dataset and no network.
+func ZipTimeZone(zip string) (string, error) {
+	if len(zip) < 3 {
+		return "", fmt.Errorf("%q: %w", zip, ErrUnknownTimeZone)
+	}
+
+	prefix := zip[:3]
+	i := sort.Search(len(zip3TimeZones), func(i int) bool {
+		return zip3TimeZones[i].to >= prefix
+	})
+
+	if i == len(zip3TimeZones) || zip3TimeZones[i].from > prefix {
+		return "", fmt.Errorf("%q: %w", zip, ErrUnknownTimeZone)
+	}
+
+	return zip3TimeZones[i].zone, nil
+}
+
+// PlaceTimeZone returns the time zone of place, preferring the dataset's own
+// time zone column over the bundled ZIP3 table, which only covers the US.
+func PlaceTimeZone(place Place) (string, error) {
+	if place.TimeZone != "" {
+		return place.TimeZone, nil
+	}
+	if place.Country != US {
+		return "", fmt.Errorf("%v: %w", place.PostalCode(), ErrUnknownTimeZone)
+	}
+	return ZipTimeZone(place.Zip)
+}
+
+// LoadPlaceTimeZone is PlaceTimeZone followed by time.LoadLocation. Binaries
+// that must work without a system zoneinfo database should import
+// time/tzdata.
+func LoadPlaceTimeZone(place Place) (*time.Location, error) {
+	name, err := PlaceTimeZone(place)
+	if err != nil {
+		return nil, err
+	}
+	return time.LoadLocation(name)
+}
+
+// TimeZoneAt returns the time zone of the ZIP code nearest to c, as
+// ResolveCoordinate finds it. It fails if there is no ZIP code within
+// maxNearestDistance miles.
+func TimeZoneAt(r Resolver, c Coordinate) (string, error) {
+	res := ResolveCoordinate(r, c)
+	if res.Zip == "" {
+		return "", fmt.Errorf("%v: %w", c, ErrUnknownTimeZone)
+	}
+	return PlaceTimeZone(res.Place)
+}
+
+// LoadTimeZone is TimeZoneAt followed by time.LoadLocation.
+func LoadTimeZone(r Resolver, c Coordinate) (*time.Location, error) {
+	name, err := TimeZoneAt(r, c)
+	if err != nil {
+		return nil, err
+	}
+	return time.LoadLocation(name)
+}
@@ -0,0 +1,76 @@
+package location
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestZipTimeZone(t *testing.T) {
+
+	zones := map[string]string{
+		"00601": "America/Puerto_Rico",
+		"02134": "America/New_York",
+		"32501": "America/Chicago",
+		"37201": "America/Chicago",
+		"37902": "America/New_York",
+		"46204": "America/Indiana/Indianapolis",
+		"57701": "America/Denver",
+		"63132": "America/Chicago",
+		"79901": "America/Denver",
+		"85001": "America/Phoenix",
+		"83814": "America/Los_Angeles",
+		"94105": "America/Los_Angeles",
+		"96813": "Pacific/Honolulu",
+		"99501": "America/Anchorage",
+	}
+
+	for zip, zone := range zones {
+		got, err := ZipTimeZone(zip)
+		assert.NoError(t, err, zip)
+		assert.Equal(t, zone, got, zip)
+	}
+
+	for _, zip := range []string{"", "09012", "96201", "abc"} {
+		_, err := ZipTimeZone(zip)
+		assert.True(t, errors.Is(err, ErrUnknownTimeZone), zip)
+	}
+}
+
+func TestZipTimeZonesSorted(t *testing.T) {
+
+	for i, z := range zip3TimeZones {
+		assert.True(t, z.from <= z.to, z.from)
+		if i > 0 {
+			assert.True(t, zip3TimeZones[i-1].to < z.from, z.from)
+		}
+	}
+}
+
+func TestTimeZoneAt(t *testing.T) {
+
+	idx, err := LoadZipCodeIndex("testdata/places.csv")
+	require.NoError(t, err)
+
+	// from the dataset's own time zone column
+	zone, err := TimeZoneAt(idx, Coordinate{Lat: 18.2, Long: -67.1})
+	require.NoError(t, err)
+	assert.Equal(t, "America/Puerto_Rico", zone)
+
+	// from the bundled table, since 96701 has no time zone in the dataset
+	zone, err = TimeZoneAt(idx, Coordinate{Lat: 21.4, Long: -157.9})
+	require.NoError(t, err)
+	assert.Equal(t, "Pacific/Honolulu", zone)
+
+	_, err = TimeZoneAt(idx, Coordinate{Lat: 0, Long: 0})
+	assert.True(t, errors.Is(err, ErrUnknownTimeZone))
+
+	// a resolved coordinate's place has the same time zone
+	loc, err := LoadPlaceTimeZone(ResolveCoordinate(idx, Coordinate{Lat: 21.4, Long: -157.9}).Place)
+	require.NoError(t, err)
+	assert.Equal(t, "Pacific/Honolulu", loc.String())
+
+	_, err = LoadPlaceTimeZone(ResolveCoordinate(idx, Coordinate{Lat: 0, Long: 0}).Place)
+	assert.True(t, errors.Is(err, ErrUnknownTimeZone))
+}
@@ -0,0 +1,229 @@
+package location
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// IssueKind classifies a problem found by ValidateDataset.
+type IssueKind string
+
+const (
+	IssueDuplicate       IssueKind = "duplicate"
+	IssueMalformedZip    IssueKind = "malformed_zip"
+	IssueNoncanonicalZip IssueKind = "noncanonical_zip"
+	IssueBadCoordinate   IssueKind = "bad_coordinate"
+	IssueOutOfRange      IssueKind = "out_of_range"
+	IssueOutsideUSBounds IssueKind = "outside_us_bounds"
+	IssueMalformedRow    IssueKind = "malformed_row"
+)
+
+// Issue is one problem with one row of a dataset.
+type Issue struct {
+	Kind IssueKind
+	Line int
+	Zip  string
+	Err  error
+}
+
+// ValidationReport lists what ValidateDataset found.
+type ValidationReport struct {
+	Name string
+
+	// Rows is the number of data rows read and Valid the number that loaded.
+	// A row outside US bounds, or whose postal code had to be normalized, is
+	// still counted as valid.
+	Rows  int
+	Valid int
+
+	// Issues are in line order.
+	Issues []Issue
+}
+
+// Counts returns the number of issues of each kind.
+func (r ValidationReport) Counts() map[IssueKind]int {
+	counts := make(map[IssueKind]int)
+	for _, issue := range r.Issues {
+		counts[issue.Kind]++
+	}
+	return counts
+}
+
+// usRegions are generous boxes around the ZIP code areas: the lower 48,
+// Alaska including the Aleutians past the antimeridian, Hawaii, Puerto Rico
+// and the Virgin Islands, Guam and the Northern Marianas, and American Samoa.
+var usRegions = []BoundingBox{
+	{South: 24.3, West: -125, North: 49.5, East: -66.8},
+	{South: 51, West: 172, North: 71.5, East: -129.9},
+	{South: 18.8, West: -160.3, North: 22.3, East: -154.7},
+	{South: 17.6, West: -68, North: 18.6, East: -64.5},
+	{South: 13.2, West: 144.6, North: 20.6, East: 146.1},
+	{South: -14.6, West: -171.1, North: -11, East: -168.1},
+}
+
+// InUSBounds reports whether c lies in or near one of the areas US ZIP codes
+// cover.
+func InUSBounds(c Coordinate) bool {
+	for _, region := range usRegions {
+		if region.Contains(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateDataset reads a ZIP code CSV stream and reports every malformed,
+// duplicate or out-of-range row, every postal code not written in its
+// normalized form, such as "601" for 00601 or a ZIP+4, and every US ZIP code
+// whose centroid lies outside US bounds. It returns the places that loaded, keeping the first
+// of any duplicates. Only a missing header or an unreadable stream is an
+// error.
+func ValidateDataset(r io.Reader, name string) (map[PostalCode]Place, ValidationReport, error) {
+	report := ValidationReport{Name: name}
+
+	decoder, err := NewDecoder(r, name)
+	if err != nil {
+		return nil, report, err
+	}
+
+	places := make(map[PostalCode]Place)
+	lines := make(map[PostalCode]int)
+
+	for {
+		place, err := decoder.Next()
+		if err == io.EOF {
+			break
+		}
+
+		var rowErr *RowError
+		if err != nil && !errors.As(err, &rowErr) {
+			return nil, report, fmt.Errorf("%s: %w", name, err)
+		}
+
+		report.Rows++
+
+		if rowErr != nil {
+			report.Issues = append(report.Issues, Issue{Kind: issueKind(rowErr.Err), Line: rowErr.Line, Zip: rowErr.Zip, Err: rowErr.Err})
+			continue
+		}
+
+		line := decoder.Line()
+
+		if first, ok := lines[place.PostalCode()]; ok {
+			report.Issues = append(report.Issues, Issue{Kind: IssueDuplicate, Line: line, Zip: place.Zip, Err: fmt.Errorf("%w, first seen on line %d", ErrDuplicateZip, first)})
+			continue
+		}
+
+		if raw := decoder.RawZip(); raw != place.Zip {
+			report.Issues = append(report.Issues, Issue{Kind: IssueNoncanonicalZip, Line: line, Zip: place.Zip, Err: fmt.Errorf("written as %q, normalized to %q", raw, place.Zip)})
+		}
+
+		if place.Country == US && !InUSBounds(place.Coordinate) {
+			report.Issues = append(report.Issues, Issue{Kind: IssueOutsideUSBounds, Line: line, Zip: place.Zip, Err: fmt.Errorf("%v is outside US bounds", place.Coordinate)})
+		}
+
+		places[place.PostalCode()] = place
+		lines[place.PostalCode()] = line
+	}
+
+	report.Valid = len(places)
+
+	return places, report, nil
+}
+
+// ValidateFile validates a ZIP code CSV file. See ValidateDataset.
+func ValidateFile(filename string) (map[PostalCode]Place, ValidationReport, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, ValidationReport{}, err
+	}
+	defer f.Close()
+
+	return ValidateDataset(f, filename)
+}
+
+func issueKind(err error) IssueKind {
+	switch {
+	case errors.Is(err, ErrEmptyZip), errors.Is(err, ErrInvalidZip), errors.Is(err, ErrInvalidPostalCode), errors.Is(err, ErrUnknownCountry):
+		return IssueMalformedZip
+	case errors.Is(err, ErrInvalidLatitude), errors.Is(err, ErrInvalidLongitude):
+		return IssueOutOfRange
+	case errors.Is(err, strconv.ErrSyntax), errors.Is(err, strconv.ErrRange):
+		return IssueBadCoordinate
+	}
+	return IssueMalformedRow
+}
+
+// Move is a postal code whose centroid differs between two datasets.
+type Move struct {
+	Key   PostalCode
+	From  Coordinate
+	To    Coordinate
+	Miles float64
+}
+
+// DatasetDiff is what changed from one dataset to another. Each list is
+// ordered by country and postal code.
+type DatasetDiff struct {
+	Added   []Place
+	Removed []Place
+
+	// Moved lists the postal codes in both datasets whose centroids are
+	// further apart than the threshold given to DiffPlaces.
+	Moved []Move
+}
+
+// DiffPlaces compares two datasets. Postal codes present in both count as
+// moved when their centroids are more than minMiles apart; pass 0 to report
+// any change at all.
+func DiffPlaces(from, to map[PostalCode]Place, minMiles float64) DatasetDiff {
+	var diff DatasetDiff
+
+	for key, place := range to {
+		old, ok := from[key]
+		if !ok {
+			diff.Added = append(diff.Added, place)
+			continue
+		}
+
+		if old.Coordinate == place.Coordinate {
+			continue
+		}
+
+		miles := Distance(old.Coordinate, place.Coordinate)
+		if miles > minMiles {
+			diff.Moved = append(diff.Moved, Move{Key: key, From: old.Coordinate, To: place.Coordinate, Miles: miles})
+		}
+	}
+
+	for key, place := range from {
+		if _, ok := to[key]; !ok {
+			diff.Removed = append(diff.Removed, place)
+		}
+	}
+
+	sortPlaces(diff.Added)
+	sortPlaces(diff.Removed)
+	sort.Slice(diff.Moved, func(i, j int) bool {
+		return postalCodeLess(diff.Moved[i].Key, diff.Moved[j].Key)
+	})
+
+	return diff
+}
+
+func sortPlaces(places []Place) {
+	sort.Slice(places, func(i, j int) bool {
+		return postalCodeLess(places[i].PostalCode(), places[j].PostalCode())
+	})
+}
+
+func postalCodeLess(a, b PostalCode) bool {
+	if a.Country != b.Country {
+		return a.Country < b.Country
+	}
+	return a.Code < b.Code
+}
@@ -0,0 +1,91 @@
+package location
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestValidateDataset(t *testing.T) {
+
+	csv := strings.Join([]string{
+		"zip,lat,lng",
+		"00601,18.180555,-66.749961",
+		"00602,118.361945,-67.175597",
+		"6313A,38.676026,-90.377994",
+		"63132,38.676026,-90.377994",
+		"63132,38.7,-90.4",
+		"96799,14.319506,-170.750246",
+		"63133,north,-90.3",
+		"63134",
+		"603,18.455183,-67.119887",
+		"00606-1234,18.158345,-66.932911",
+	}, "\n")
+
+	places, report, err := ValidateDataset(strings.NewReader(csv), "test.csv")
+	require.NoError(t, err)
+
+	assert.Equal(t, 10, report.Rows)
+	assert.Equal(t, 5, report.Valid)
+	assert.Len(t, places, 5)
+
+	kinds := make([]IssueKind, 0, len(report.Issues))
+	lines := make([]int, 0, len(report.Issues))
+	for _, issue := range report.Issues {
+		kinds = append(kinds, issue.Kind)
+		lines = append(lines, issue.Line)
+	}
+
+	assert.Equal(t, []IssueKind{IssueOutOfRange, IssueMalformedZip, IssueDuplicate, IssueOutsideUSBounds, IssueBadCoordinate, IssueMalformedRow, IssueNoncanonicalZip, IssueNoncanonicalZip}, kinds)
+	assert.Equal(t, []int{3, 4, 6, 7, 8, 9, 10, 11}, lines)
+	assert.Equal(t, "00603", report.Issues[6].Zip)
+	assert.Equal(t, "00606", report.Issues[7].Zip)
+	assert.Equal(t, 1, report.Counts()[IssueDuplicate])
+
+	// the first of a duplicate pair is kept
+	assert.Equal(t, 38.676026, places[PostalCode{Country: US, Code: "63132"}].Coordinate.Lat)
+}
+
+func TestInUSBounds(t *testing.T) {
+
+	assert.True(t, InUSBounds(stLouis))
+	assert.True(t, InUSBounds(Coordinate{Lat: 52.2, Long: 174.2}))    // Adak, Aleutians
+	assert.True(t, InUSBounds(Coordinate{Lat: 13.44, Long: 144.79}))  // Hagåtña, Guam
+	assert.True(t, InUSBounds(Coordinate{Lat: -14.28, Long: -170.7})) // Pago Pago
+	assert.False(t, InUSBounds(Coordinate{Lat: 51.5, Long: -0.13}))
+	assert.False(t, InUSBounds(Coordinate{Lat: 19.43, Long: -99.13}))
+}
+
+func TestDiffPlaces(t *testing.T) {
+
+	place := func(zip string, lat, long float64) Place {
+		return Place{Country: US, Zip: zip, Coordinate: Coordinate{Lat: lat, Long: long}}
+	}
+	keyed := func(places ...Place) map[PostalCode]Place {
+		m := make(map[PostalCode]Place)
+		for _, p := range places {
+			m[p.PostalCode()] = p
+		}
+		return m
+	}
+
+	from := keyed(place("00601", 18.180555, -66.749961), place("63132", 38.676026, -90.377994), place("64105", 39.1025, -94.5883), place("63101", 38.6315, -90.1922))
+	to := keyed(place("00601", 18.180555, -66.749961), place("63132", 38.676026, -90.3), place("64105", 39.10251, -94.5883), place("63102", 38.6352, -90.1866))
+
+	diff := DiffPlaces(from, to, 0)
+
+	require.Len(t, diff.Added, 1)
+	assert.Equal(t, "63102", diff.Added[0].Zip)
+	require.Len(t, diff.Removed, 1)
+	assert.Equal(t, "63101", diff.Removed[0].Zip)
+	require.Len(t, diff.Moved, 2)
+	assert.Equal(t, "63132", diff.Moved[0].Key.Code)
+	assert.InDelta(t, 4.2, diff.Moved[0].Miles, 0.1)
+	assert.Equal(t, "64105", diff.Moved[1].Key.Code)
+
+	// a tiny resurvey drops out above a threshold
+	diff = DiffPlaces(from, to, 0.5)
+	require.Len(t, diff.Moved, 1)
+	assert.Equal(t, "63132", diff.Moved[0].Key.Code)
+}
@@ -0,0 +1,11 @@
+// Package zipdata holds the ZIP code dataset compiled into the binaries.
+package zipdata
+
+import (
+	_ "embed"
+)
+
+// CSV is the dataset, a ZIP,LAT,LNG file as location.ReadZipCodes reads.
+//
+//go:embed zip.csv
+var CSV string
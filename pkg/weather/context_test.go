@@ -0,0 +1,98 @@
+package weather
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"sketch-go-course/pkg/location"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stallingClient answers each request with the mock responses, except those
+// whose path starts with stall, which wait for their context to end and
+// fail with its error. An empty stall stalls nothing. Each request's path is
+// sent to started, if it is not nil, before it is answered.
+func stallingClient(stall string, started chan<- string) *http.Client {
+	return &http.Client{
+		Transport: MockClient{
+			Fn: func(request *http.Request) (*http.Response, error) {
+				if started != nil {
+					started <- request.URL.Path
+				}
+
+				if stall != "" && strings.HasPrefix(request.URL.Path, stall) {
+					<-request.Context().Done()
+					return nil, request.Context().Err()
+				}
+
+				body := mockResponse
+				if request.URL.Path == "/gridpoints/SJU/107,106/forecast" {
+					body = mockResponse2
+				}
+				return &http.Response{StatusCode: http.StatusOK, Request: request, Header: http.Header{}, Body: ioutil.NopCloser(bytes.NewReader([]byte(body)))}, nil
+			},
+		},
+	}
+}
+
+func TestFetchForecastContextCancel(t *testing.T) {
+
+	started := make(chan string, 1)
+	c := Client{Client: stallingClient("/points/", started)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := c.FetchForecastContext(ctx, location.Coordinate{Lat: 38.676026, Long: -90.377994})
+		errs <- err
+	}()
+
+	// cancel once the /points/ request is in flight
+	<-started
+	cancel()
+
+	err := <-errs
+	assert.True(t, errors.Is(err, ErrNetwork))
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestFetchForecastTimeoutCoversBothRequests(t *testing.T) {
+
+	coordinate := location.Coordinate{Lat: 38.676026, Long: -90.377994}
+
+	// both requests share one deadline rather than each getting the timeout
+	var deadlines []time.Time
+	c := Client{
+		Client: &http.Client{
+			Transport: MockClient{
+				Fn: func(request *http.Request) (*http.Response, error) {
+					deadline, ok := request.Context().Deadline()
+					require.True(t, ok)
+					deadlines = append(deadlines, deadline)
+					return stallingClient("", nil).Transport.RoundTrip(request)
+				},
+			},
+		},
+		Timeout: time.Hour,
+	}
+
+	forecast, err := c.FetchForecast(coordinate)
+	require.NoError(t, err)
+	assert.Len(t, forecast.Properties.Periods, 14)
+	require.Len(t, deadlines, 2)
+	assert.Equal(t, deadlines[0], deadlines[1])
+
+	// a request still waiting when the timeout passes fails with it
+	c = Client{Client: stallingClient("/gridpoints/", nil), Timeout: time.Millisecond}
+
+	_, err = c.FetchForecastContext(context.Background(), coordinate)
+	assert.True(t, errors.Is(err, ErrNetwork))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
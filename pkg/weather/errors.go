@@ -0,0 +1,159 @@
+package weather
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// The kinds of upstream failure. Every error FetchForecast returns is an
+// *UpstreamError matching exactly one of these with errors.Is.
+var (
+	// ErrNotFound means upstream has no data for the point, usually because
+	// it is outside the area the National Weather Service covers.
+	ErrNotFound = errors.New("no forecast for this point")
+
+	// ErrRateLimited means upstream refused the request for being too
+	// frequent. RetryAfter says how long it asked us to wait.
+	ErrRateLimited = errors.New("rate limited by upstream")
+
+	// ErrServer means upstream failed with a 5xx status.
+	ErrServer = errors.New("upstream server error")
+
+	// ErrUnexpectedStatus means upstream answered with a status none of the
+	// other kinds cover, such as 400 for a malformed request.
+	ErrUnexpectedStatus = errors.New("unexpected upstream status")
+
+	// ErrDecode means the response body could not be decoded.
+	ErrDecode = errors.New("could not decode upstream response")
+
+	// ErrNetwork means no response was received: DNS, connection, TLS or
+	// timeout failures, a cancelled context, or a body cut short. The
+	// context's error can be told apart with errors.Is.
+	ErrNetwork = errors.New("could not reach upstream")
+)
+
+// Problem is an RFC 7807 problem details document, which upstream sends
+// with application/problem+json error responses.
+type Problem struct {
+	Type          string `json:"type"`
+	Title         string `json:"title"`
+	Status        int    `json:"status"`
+	Detail        string `json:"detail"`
+	Instance      string `json:"instance"`
+	CorrelationID string `json:"correlationId"`
+}
+
+// UpstreamError describes a failed upstream request.
+type UpstreamError struct {
+	// Kind is one of the Err variables above.
+	Kind error
+
+	URL string
+
+	// StatusCode is zero for network failures.
+	StatusCode int
+
+	// Problem holds the problem details upstream sent, if any.
+	Problem *Problem
+
+	// RetryAfter is how long upstream asked us to wait, from its
+	// Retry-After header, or zero.
+	RetryAfter time.Duration
+
+	// Attempts is how many times the request was made.
+	Attempts int
+
+	// Err is the underlying error, if there is one: the transport error for
+	// ErrNetwork or the JSON error for ErrDecode.
+	Err error
+}
+
+func (e *UpstreamError) Error() string {
+	msg := fmt.Sprintf("%v: %s", e.Kind, e.URL)
+
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(": %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+
+	if e.Problem != nil {
+		switch {
+		case e.Problem.Detail != "":
+			msg += ": " + e.Problem.Detail
+		case e.Problem.Title != "":
+			msg += ": " + e.Problem.Title
+		}
+	}
+
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+
+	return msg
+}
+
+// Is matches the error's Kind, so errors.Is(err, ErrNotFound) works.
+func (e *UpstreamError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Unwrap returns the underlying error, so errors.Is(err,
+// context.DeadlineExceeded) also works.
+func (e *UpstreamError) Unwrap() error {
+	return e.Err
+}
+
+// statusError builds the error for a non-2xx response with the given body.
+func statusError(url string, res *http.Response, body []byte, now time.Time) *UpstreamError {
+	e := &UpstreamError{URL: url, StatusCode: res.StatusCode}
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		e.Kind = ErrNotFound
+	case res.StatusCode == http.StatusTooManyRequests:
+		e.Kind = ErrRateLimited
+	case res.StatusCode >= 500:
+		e.Kind = ErrServer
+	default:
+		e.Kind = ErrUnexpectedStatus
+	}
+
+	e.RetryAfter = parseRetryAfter(res.Header.Get("Retry-After"), now)
+
+	// upstream usually says problem+json, but a plain JSON body with the
+	// same fields is just as useful
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType == "application/problem+json" || mediaType == "application/json" || mediaType == "" {
+		var problem Problem
+		if json.Unmarshal(body, &problem) == nil && (problem.Title != "" || problem.Detail != "" || problem.Type != "") {
+			e.Problem = &problem
+		}
+	}
+
+	return e
+}
+
+// parseRetryAfter reads a Retry-After header, either seconds or an HTTP
+// date, as a duration from now. Anything else is zero.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+
+	return 0
+}
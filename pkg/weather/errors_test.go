@@ -0,0 +1,142 @@
+package weather
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"sketch-go-course/pkg/location"
+	"testing"
+	"time"
+)
+
+// respond returns a client whose /points/ requests get status, headers and
+// body, and whose forecast requests succeed.
+func respond(status int, header http.Header, body string) Client {
+	return Client{
+		Precision: DefaultPrecision,
+		Client: &http.Client{
+			Transport: MockClient{
+				Fn: func(request *http.Request) (*http.Response, error) {
+					if request.URL.Path == "/gridpoints/SJU/107,106/forecast" {
+						return &http.Response{StatusCode: http.StatusOK, Request: request, Header: http.Header{}, Body: ioutil.NopCloser(bytes.NewReader([]byte(mockResponse2)))}, nil
+					}
+					if header == nil {
+						header = http.Header{}
+					}
+					return &http.Response{StatusCode: status, Request: request, Header: header, Body: ioutil.NopCloser(bytes.NewReader([]byte(body)))}, nil
+				},
+			},
+		},
+	}
+}
+
+func TestFetchForecastErrors(t *testing.T) {
+
+	problemJSON := http.Header{"Content-Type": []string{"application/problem+json"}}
+
+	tests := map[string]struct {
+		client Client
+		kind   error
+		status int
+		check  func(t *testing.T, e *UpstreamError)
+	}{
+		"outside coverage": {
+			client: respond(http.StatusNotFound, problemJSON, `{
+				"correlationId": "1a2b3c",
+				"title": "Data Unavailable For Requested Point",
+				"type": "https://api.weather.gov/problems/InvalidPoint",
+				"status": 404,
+				"detail": "Unable to provide data for requested point 51.5,-0.13",
+				"instance": "https://api.weather.gov/requests/1a2b3c"
+			}`),
+			kind:   ErrNotFound,
+			status: http.StatusNotFound,
+			check: func(t *testing.T, e *UpstreamError) {
+				require.NotNil(t, e.Problem)
+				assert.Equal(t, "https://api.weather.gov/problems/InvalidPoint", e.Problem.Type)
+				assert.Equal(t, "1a2b3c", e.Problem.CorrelationID)
+				assert.Contains(t, e.Error(), "Unable to provide data for requested point")
+			},
+		},
+		"rate limited": {
+			client: respond(http.StatusTooManyRequests, http.Header{"Retry-After": []string{"30"}}, ""),
+			kind:   ErrRateLimited,
+			status: http.StatusTooManyRequests,
+			check: func(t *testing.T, e *UpstreamError) {
+				assert.Equal(t, 30*time.Second, e.RetryAfter)
+				assert.Nil(t, e.Problem)
+			},
+		},
+		"server error": {
+			client: respond(http.StatusInternalServerError, problemJSON, `{"title": "Unexpected Problem", "status": 500}`),
+			kind:   ErrServer,
+			status: http.StatusInternalServerError,
+			check: func(t *testing.T, e *UpstreamError) {
+				assert.Equal(t, "Unexpected Problem", e.Problem.Title)
+			},
+		},
+		"bad request": {
+			client: respond(http.StatusBadRequest, nil, "nope"),
+			kind:   ErrUnexpectedStatus,
+			status: http.StatusBadRequest,
+		},
+		"bad json": {
+			client: respond(http.StatusOK, nil, `{"properties": `),
+			kind:   ErrDecode,
+			status: http.StatusOK,
+		},
+		"no forecast url": {
+			client: respond(http.StatusOK, nil, `{"properties": {}}`),
+			kind:   ErrDecode,
+			status: http.StatusOK,
+		},
+		"network": {
+			client: Client{Precision: DefaultPrecision, Client: &http.Client{Transport: MockClient{Fn: func(*http.Request) (*http.Response, error) {
+				return nil, errors.New("connection refused")
+			}}}},
+			kind: ErrNetwork,
+			check: func(t *testing.T, e *UpstreamError) {
+				assert.Contains(t, e.Error(), "connection refused")
+			},
+		},
+	}
+
+	kinds := []error{ErrNotFound, ErrRateLimited, ErrServer, ErrUnexpectedStatus, ErrDecode, ErrNetwork}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+
+			forecast, err := test.client.FetchForecast(location.Coordinate{Lat: 38.676026, Long: -90.377994})
+			require.Error(t, err)
+			assert.Empty(t, forecast.Properties.Periods)
+
+			for _, kind := range kinds {
+				assert.Equal(t, kind == test.kind, errors.Is(err, kind), kind.Error())
+			}
+
+			var e *UpstreamError
+			require.True(t, errors.As(err, &e))
+			assert.Equal(t, test.status, e.StatusCode)
+			assert.Equal(t, "https://api.weather.gov/points/38.676,-90.378", e.URL)
+
+			if test.check != nil {
+				test.check(t, e)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+
+	now := time.Date(2020, 4, 24, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 120*time.Second, parseRetryAfter("120", now))
+	assert.Equal(t, 90*time.Second, parseRetryAfter("Fri, 24 Apr 2020 12:01:30 GMT", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("Fri, 24 Apr 2020 11:00:00 GMT", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("-5", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+}
@@ -0,0 +1,387 @@
+package weather
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheStatus says where a response came from when the client's transport is
+// a CacheTransport. It is empty without one.
+type CacheStatus string
+
+const (
+	// CacheMiss means the response came from upstream.
+	CacheMiss CacheStatus = "miss"
+	// CacheHit means a fresh cached response was used without asking
+	// upstream.
+	CacheHit CacheStatus = "hit"
+	// CacheRevalidated means upstream confirmed with a 304 that a stale
+	// cached response was still current, and the cached body was used.
+	CacheRevalidated CacheStatus = "revalidated"
+)
+
+// CacheStatusHeader is the response header a CacheTransport reports the
+// CacheStatus in.
+const CacheStatusHeader = "X-Cache-Status"
+
+// DefaultCacheEntries is how many responses a CacheTransport keeps when
+// MaxEntries is not set.
+const DefaultCacheEntries = 1000
+
+// CacheTransport is an http.RoundTripper that caches successful GET responses
+// in memory, as a private HTTP cache would. A response is fresh for its
+// Cache-Control max-age, or failing that until its Expires date, and is
+// served without asking upstream while fresh. Once stale it is revalidated
+// with If-None-Match and If-Modified-Since from its ETag and Last-Modified,
+// and a 304 answer is served from the cache. Responses marked no-store are
+// not kept; no-cache ones are revalidated every time.
+//
+// Requests that carry their own validators or a Range pass straight through.
+// Every other GET response gets a CacheStatusHeader. A Client's Limiter is
+// taken here, for each request sent upstream, rather than by the Client. It
+// is safe for concurrent use.
+type CacheTransport struct {
+	// Transport makes the requests, http.DefaultTransport if nil.
+	Transport http.RoundTripper
+
+	// MaxEntries caps how many responses are kept, dropping the least
+	// recently used. Zero means DefaultCacheEntries.
+	MaxEntries int
+
+	// Clock, if set, is what ages are measured on.
+	Clock Clock
+
+	mu      sync.Mutex
+	entries lru
+}
+
+// NewCacheTransport returns a CacheTransport sending requests through
+// transport and keeping up to maxEntries responses.
+func NewCacheTransport(transport http.RoundTripper, maxEntries int) *CacheTransport {
+	return &CacheTransport{Transport: transport, MaxEntries: maxEntries}
+}
+
+// upstreamLimiter is a transport that takes the client's Limiter itself, just
+// before each request it sends upstream, so responses it answers on its own
+// cost nothing.
+type upstreamLimiter interface {
+	limitsUpstream()
+}
+
+var _ upstreamLimiter = (*CacheTransport)(nil)
+
+type cachedResponse struct {
+	key string
+
+	status int
+	header http.Header
+	body   []byte
+
+	// vary holds the request header values the response varies by
+	vary map[string]string
+
+	// stored is when the response was received or last revalidated, and
+	// age how old it already was then
+	stored time.Time
+	age    time.Duration
+}
+
+func (t *CacheTransport) transport() http.RoundTripper {
+	if t.Transport == nil {
+		return http.DefaultTransport
+	}
+	return t.Transport
+}
+
+func (t *CacheTransport) clock() Clock {
+	if t.Clock == nil {
+		return realClock{}
+	}
+	return t.Clock
+}
+
+// cacheable reports whether the transport handles req rather than passing it
+// straight through.
+func cacheable(req *http.Request) bool {
+	return req.Method == http.MethodGet &&
+		req.Header.Get("Range") == "" &&
+		req.Header.Get("If-None-Match") == "" &&
+		req.Header.Get("If-Modified-Since") == ""
+}
+
+func (t *CacheTransport) limitsUpstream() {}
+
+func (t *CacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !cacheable(req) {
+		return limitedRoundTrip(t.transport(), req)
+	}
+
+	requestControl := parseCacheControl(req.Header.Get("Cache-Control"))
+	_, noCache := requestControl["no-cache"]
+	_, noStore := requestControl["no-store"]
+
+	t.mu.Lock()
+	entry := t.lookup(req)
+	var cached cachedResponse
+	if entry != nil {
+		if !noCache && entry.fresh(t.clock().Now()) {
+			res := entry.response(req, CacheHit, t.clock().Now())
+			t.mu.Unlock()
+			return res, nil
+		}
+		cached = *entry
+	}
+	t.mu.Unlock()
+
+	outgoing := req
+	if entry != nil {
+		etag, lastModified := cached.header.Get("ETag"), cached.header.Get("Last-Modified")
+		if etag != "" || lastModified != "" {
+			outgoing = req.Clone(req.Context())
+			if etag != "" {
+				outgoing.Header.Set("If-None-Match", etag)
+			}
+			if lastModified != "" {
+				outgoing.Header.Set("If-Modified-Since", lastModified)
+			}
+		}
+	}
+
+	res, err := limitedRoundTrip(t.transport(), outgoing)
+	if err != nil {
+		return nil, err
+	}
+
+	now := t.clock().Now()
+
+	if res.StatusCode == http.StatusNotModified && outgoing != req {
+		_, _ = io.Copy(ioutil.Discard, res.Body)
+		_ = res.Body.Close()
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		// another request may have replaced or dropped the entry meanwhile,
+		// so update a copy and store that
+		revalidated := cached
+		revalidated.header = cached.header.Clone()
+		for name, values := range res.Header {
+			switch name {
+			case "Content-Length", "Content-Encoding", "Transfer-Encoding":
+				continue
+			}
+			revalidated.header[name] = values
+		}
+		revalidated.stored, revalidated.age = now, responseAge(res.Header, now)
+
+		t.store(&revalidated)
+		return revalidated.response(req, CacheRevalidated, now), nil
+	}
+
+	responseControl := parseCacheControl(res.Header.Get("Cache-Control"))
+	_, responseNoStore := responseControl["no-store"]
+
+	if res.StatusCode != http.StatusOK || noStore || responseNoStore || res.Header.Get("Vary") == "*" {
+		res.Header.Set(CacheStatusHeader, string(CacheMiss))
+		return res, nil
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	fresh := &cachedResponse{
+		key:    req.URL.String(),
+		status: res.StatusCode,
+		header: res.Header.Clone(),
+		body:   body,
+		vary:   varyValues(req, res.Header),
+		stored: now,
+		age:    responseAge(res.Header, now),
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// a response that can neither be fresh nor revalidated is no use later
+	if fresh.lifetime() > 0 || fresh.header.Get("ETag") != "" || fresh.header.Get("Last-Modified") != "" {
+		t.store(fresh)
+	} else {
+		t.remove(fresh.key)
+	}
+
+	return fresh.response(req, CacheMiss, now), nil
+}
+
+// Len is the number of responses cached.
+func (t *CacheTransport) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.entries.len()
+}
+
+// lookup returns the cached response for req if there is one it can stand
+// for. The caller holds t.mu.
+func (t *CacheTransport) lookup(req *http.Request) *cachedResponse {
+	key := req.URL.String()
+
+	cached, ok := t.entries.peek(key)
+	if !ok {
+		return nil
+	}
+
+	entry := cached.(*cachedResponse)
+	for name, value := range entry.vary {
+		if req.Header.Get(name) != value {
+			return nil
+		}
+	}
+
+	t.entries.get(key)
+	return entry
+}
+
+// store adds or replaces entry, dropping the least recently used responses
+// past MaxEntries. The caller holds t.mu.
+func (t *CacheTransport) store(entry *cachedResponse) {
+	t.entries.put(entry.key, entry)
+
+	max := t.MaxEntries
+	if max <= 0 {
+		max = DefaultCacheEntries
+	}
+	t.entries.trim(max)
+}
+
+// remove drops the response cached under key. The caller holds t.mu.
+func (t *CacheTransport) remove(key string) {
+	t.entries.remove(key)
+}
+
+// lifetime is how long the response is fresh for from when it was created:
+// its max-age, or the time from its Date to its Expires. no-cache responses
+// and those without either are stale at once.
+func (e *cachedResponse) lifetime() time.Duration {
+	control := parseCacheControl(e.header.Get("Cache-Control"))
+
+	if _, ok := control["no-cache"]; ok {
+		return 0
+	}
+
+	if maxAge, ok := control["max-age"]; ok {
+		seconds, err := strconv.Atoi(maxAge)
+		if err != nil || seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if expiresStr := e.header.Get("Expires"); expiresStr != "" {
+		// an invalid date such as "0" means already expired
+		expires, err := http.ParseTime(expiresStr)
+		if err != nil {
+			return 0
+		}
+
+		date, err := http.ParseTime(e.header.Get("Date"))
+		if err != nil {
+			date = e.stored
+		}
+		return expires.Sub(date)
+	}
+
+	return 0
+}
+
+func (e *cachedResponse) fresh(now time.Time) bool {
+	return e.age+now.Sub(e.stored) < e.lifetime()
+}
+
+// response builds a response to req from the cached one, reporting status.
+// The caller holds the lock guarding e.
+func (e *cachedResponse) response(req *http.Request, status CacheStatus, now time.Time) *http.Response {
+	header := e.header.Clone()
+	header.Set(CacheStatusHeader, string(status))
+	if status != CacheMiss {
+		header.Set("Age", strconv.Itoa(int((e.age + now.Sub(e.stored)).Seconds())))
+	}
+
+	return &http.Response{
+		Status:        strconv.Itoa(e.status) + " " + http.StatusText(e.status),
+		StatusCode:    e.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}
+}
+
+// responseAge is how old a response received at now already was, from its
+// Age header and how long ago its Date was.
+func responseAge(header http.Header, now time.Time) time.Duration {
+	var age time.Duration
+
+	if seconds, err := strconv.Atoi(header.Get("Age")); err == nil && seconds > 0 {
+		age = time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(header.Get("Date")); err == nil && now.Sub(date) > age {
+		age = now.Sub(date)
+	}
+
+	return age
+}
+
+// varyValues returns the values in req of the headers a response with header
+// varies by.
+func varyValues(req *http.Request, header http.Header) map[string]string {
+	var vary map[string]string
+
+	for _, line := range header["Vary"] {
+		for _, name := range strings.Split(line, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			if vary == nil {
+				vary = make(map[string]string)
+			}
+			vary[name] = req.Header.Get(name)
+		}
+	}
+
+	return vary
+}
+
+// parseCacheControl splits a Cache-Control header into its directives, with
+// names lowercased and quotes removed from values.
+func parseCacheControl(value string) map[string]string {
+	directives := make(map[string]string)
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, arg := part, ""
+		if i := strings.IndexByte(part, '='); i >= 0 {
+			name, arg = strings.TrimSpace(part[:i]), strings.Trim(strings.TrimSpace(part[i+1:]), `"`)
+		}
+		directives[strings.ToLower(name)] = arg
+	}
+
+	return directives
+}
@@ -0,0 +1,291 @@
+package weather
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"sketch-go-course/pkg/location"
+	"testing"
+	"time"
+)
+
+// cacheOrigin is an upstream for CacheTransport tests. Each request is
+// recorded and answered by respond.
+type cacheOrigin struct {
+	requests []*http.Request
+	respond  func(request *http.Request) (int, http.Header, string)
+}
+
+func (o *cacheOrigin) RoundTrip(request *http.Request) (*http.Response, error) {
+	o.requests = append(o.requests, request)
+	status, header, body := o.respond(request)
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{StatusCode: status, Request: request, Header: header, Body: ioutil.NopCloser(bytes.NewReader([]byte(body)))}, nil
+}
+
+func cacheGet(t *testing.T, transport http.RoundTripper, url string, header http.Header) (*http.Response, string) {
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	for name, values := range header {
+		request.Header[name] = values
+	}
+
+	res, err := transport.RoundTrip(request)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	return res, string(body)
+}
+
+func TestCacheTransportMaxAge(t *testing.T) {
+
+	clock := &fakeClock{now: time.Date(2020, 4, 24, 12, 0, 0, 0, time.UTC)}
+	origin := &cacheOrigin{respond: func(request *http.Request) (int, http.Header, string) {
+		if request.Header.Get("If-None-Match") == `"v1"` {
+			return http.StatusNotModified, http.Header{"Cache-Control": []string{"max-age=60"}}, ""
+		}
+		return http.StatusOK, http.Header{"Cache-Control": []string{"public, max-age=60"}, "Etag": []string{`"v1"`}}, "forecast"
+	}}
+
+	transport := &CacheTransport{Transport: origin, Clock: clock}
+	url := "https://api.weather.gov/gridpoints/LSX/87,75/forecast"
+
+	res, body := cacheGet(t, transport, url, nil)
+	assert.Equal(t, "forecast", body)
+	assert.Equal(t, string(CacheMiss), res.Header.Get(CacheStatusHeader))
+
+	clock.now = clock.now.Add(30 * time.Second)
+	res, body = cacheGet(t, transport, url, nil)
+	assert.Equal(t, "forecast", body)
+	assert.Equal(t, string(CacheHit), res.Header.Get(CacheStatusHeader))
+	assert.Equal(t, "30", res.Header.Get("Age"))
+	assert.Len(t, origin.requests, 1)
+
+	// stale, so upstream is asked whether it changed
+	clock.now = clock.now.Add(time.Minute)
+	res, body = cacheGet(t, transport, url, nil)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "forecast", body)
+	assert.Equal(t, string(CacheRevalidated), res.Header.Get(CacheStatusHeader))
+	require.Len(t, origin.requests, 2)
+	assert.Equal(t, `"v1"`, origin.requests[1].Header.Get("If-None-Match"))
+
+	// revalidating made it fresh again
+	clock.now = clock.now.Add(30 * time.Second)
+	res, _ = cacheGet(t, transport, url, nil)
+	assert.Equal(t, string(CacheHit), res.Header.Get(CacheStatusHeader))
+	assert.Len(t, origin.requests, 2)
+
+	// the caller can insist on asking upstream
+	res, _ = cacheGet(t, transport, url, http.Header{"Cache-Control": []string{"no-cache"}})
+	assert.Equal(t, string(CacheRevalidated), res.Header.Get(CacheStatusHeader))
+	assert.Len(t, origin.requests, 3)
+}
+
+func TestCacheTransportExpires(t *testing.T) {
+
+	clock := &fakeClock{now: time.Date(2020, 4, 24, 12, 0, 0, 0, time.UTC)}
+	modified := "Fri, 24 Apr 2020 11:00:00 GMT"
+	version := "old"
+
+	origin := &cacheOrigin{respond: func(request *http.Request) (int, http.Header, string) {
+		header := http.Header{
+			// upstream's clock is ten seconds behind ours
+			"Date":          []string{"Fri, 24 Apr 2020 11:59:50 GMT"},
+			"Expires":       []string{"Fri, 24 Apr 2020 12:04:50 GMT"},
+			"Last-Modified": []string{modified},
+		}
+		if request.Header.Get("If-Modified-Since") == modified && version == "old" {
+			return http.StatusNotModified, nil, ""
+		}
+		return http.StatusOK, header, version
+	}}
+
+	transport := NewCacheTransport(origin, 0)
+	transport.Clock = clock
+	url := "https://api.weather.gov/points/38.676,-90.378"
+
+	_, body := cacheGet(t, transport, url, nil)
+	assert.Equal(t, "old", body)
+
+	// five minutes from Date, measured from when we received it
+	clock.now = clock.now.Add(4*time.Minute + 49*time.Second)
+	res, _ := cacheGet(t, transport, url, nil)
+	assert.Equal(t, string(CacheHit), res.Header.Get(CacheStatusHeader))
+
+	clock.now = clock.now.Add(2 * time.Second)
+	version = "new"
+	res, body = cacheGet(t, transport, url, nil)
+	assert.Equal(t, string(CacheMiss), res.Header.Get(CacheStatusHeader))
+	assert.Equal(t, "new", body)
+	require.Len(t, origin.requests, 2)
+	assert.Equal(t, modified, origin.requests[1].Header.Get("If-Modified-Since"))
+}
+
+func TestCacheTransportDoesNotStore(t *testing.T) {
+
+	tests := map[string]struct {
+		status  int
+		header  http.Header
+		request http.Header
+		stored  bool
+	}{
+		"no-store":                  {status: 200, header: http.Header{"Cache-Control": []string{"no-store, max-age=60"}}},
+		"request no-store":          {status: 200, header: http.Header{"Cache-Control": []string{"max-age=60"}}, request: http.Header{"Cache-Control": []string{"no-store"}}},
+		"no freshness or validator": {status: 200},
+		"error status":              {status: 503, header: http.Header{"Cache-Control": []string{"max-age=60"}}},
+		"vary star":                 {status: 200, header: http.Header{"Cache-Control": []string{"max-age=60"}, "Vary": []string{"*"}}},
+		"no-cache with validator":   {status: 200, header: http.Header{"Cache-Control": []string{"no-cache"}, "Etag": []string{`"x"`}}, stored: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+
+			origin := &cacheOrigin{respond: func(request *http.Request) (int, http.Header, string) {
+				return test.status, test.header.Clone(), "body"
+			}}
+			transport := &CacheTransport{Transport: origin}
+
+			for i := 0; i < 2; i++ {
+				res, body := cacheGet(t, transport, "https://api.weather.gov/points/1,2", test.request)
+				assert.Equal(t, "body", body)
+				assert.Equal(t, string(CacheMiss), res.Header.Get(CacheStatusHeader))
+			}
+
+			assert.Len(t, origin.requests, 2)
+			if test.stored {
+				assert.Equal(t, 1, transport.Len())
+				assert.Equal(t, `"x"`, origin.requests[1].Header.Get("If-None-Match"))
+			} else {
+				assert.Equal(t, 0, transport.Len())
+			}
+		})
+	}
+}
+
+func TestCacheTransportVaryAndEviction(t *testing.T) {
+
+	origin := &cacheOrigin{respond: func(request *http.Request) (int, http.Header, string) {
+		return http.StatusOK, http.Header{"Cache-Control": []string{"max-age=60"}, "Vary": []string{"Accept, Feature-Flags"}}, request.Header.Get("Accept")
+	}}
+	transport := &CacheTransport{Transport: origin, MaxEntries: 2}
+
+	geo := http.Header{"Accept": []string{"application/geo+json"}}
+	ld := http.Header{"Accept": []string{"application/ld+json"}}
+
+	cacheGet(t, transport, "https://api.weather.gov/a", geo)
+	res, _ := cacheGet(t, transport, "https://api.weather.gov/a", geo)
+	assert.Equal(t, string(CacheHit), res.Header.Get(CacheStatusHeader))
+
+	res, body := cacheGet(t, transport, "https://api.weather.gov/a", ld)
+	assert.Equal(t, string(CacheMiss), res.Header.Get(CacheStatusHeader))
+	assert.Equal(t, "application/ld+json", body)
+
+	cacheGet(t, transport, "https://api.weather.gov/b", geo)
+	cacheGet(t, transport, "https://api.weather.gov/c", geo)
+	assert.Equal(t, 2, transport.Len())
+
+	// a was used least recently, so it went first
+	res, _ = cacheGet(t, transport, "https://api.weather.gov/a", ld)
+	assert.Equal(t, string(CacheMiss), res.Header.Get(CacheStatusHeader))
+	res, _ = cacheGet(t, transport, "https://api.weather.gov/c", geo)
+	assert.Equal(t, string(CacheHit), res.Header.Get(CacheStatusHeader))
+
+	// requests with their own validators are passed through untouched
+	res, _ = cacheGet(t, transport, "https://api.weather.gov/c", http.Header{"If-None-Match": []string{`"y"`}})
+	assert.Equal(t, "", res.Header.Get(CacheStatusHeader))
+}
+
+func TestFetchForecastCacheStatus(t *testing.T) {
+
+	cached := http.Header{"Cache-Control": []string{"max-age=3600"}}
+
+	httpClient := &http.Client{
+		Transport: NewCacheTransport(MockClient{
+			Fn: func(request *http.Request) (*http.Response, error) {
+				body := mockResponse
+				if request.URL.Path == "/gridpoints/SJU/107,106/forecast" {
+					body = mockResponse2
+				}
+				return &http.Response{StatusCode: http.StatusOK, Request: request, Header: cached.Clone(), Body: ioutil.NopCloser(bytes.NewReader([]byte(body)))}, nil
+			},
+		}, 0),
+	}
+
+	// enough tokens for the first lookup only; hits must not need more
+	c := Client{Client: httpClient, Limiter: NewLimiter(0.0001, 2, 0)}
+	coordinate := location.Coordinate{Lat: 38.676026, Long: -90.377994}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	forecast, err := c.FetchForecastContext(ctx, coordinate)
+	require.NoError(t, err)
+	assert.Equal(t, CacheMiss, forecast.Cache)
+
+	forecast, err = c.FetchForecastContext(ctx, coordinate)
+	require.NoError(t, err)
+	assert.Equal(t, CacheHit, forecast.Cache)
+	assert.Len(t, forecast.Properties.Periods, 14)
+
+	// without a CacheTransport there is no status
+	forecast, err = Client{Client: stallingClient("", nil)}.FetchForecast(coordinate)
+	require.NoError(t, err)
+	assert.Equal(t, CacheStatus(""), forecast.Cache)
+}
+
+func TestCacheTransportLimitsStaleEntries(t *testing.T) {
+
+	clock := &fakeClock{now: time.Date(2020, 4, 24, 12, 0, 0, 0, time.UTC)}
+	calls := 0
+
+	transport := NewCacheTransport(MockClient{
+		Fn: func(request *http.Request) (*http.Response, error) {
+			calls++
+			return &http.Response{StatusCode: http.StatusOK, Request: request, Header: http.Header{"Cache-Control": []string{"max-age=60"}}, Body: ioutil.NopCloser(bytes.NewReader([]byte(mockResponse2)))}, nil
+		},
+	}, 0)
+	transport.Clock = clock
+
+	// one token, and the next not due for hours; the fake clock waits at once
+	c := Client{Client: &http.Client{Transport: transport}, Clock: clock, Limiter: NewLimiter(0.0001, 1, 1)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var forecast Forecast
+	_, cache, err := c.getJSON(ctx, "https://api.weather.gov/gridpoints/SJU/107,106/forecast", &forecast)
+	require.NoError(t, err)
+	assert.Equal(t, CacheMiss, cache)
+
+	_, cache, err = c.getJSON(ctx, "https://api.weather.gov/gridpoints/SJU/107,106/forecast", &forecast)
+	require.NoError(t, err)
+	assert.Equal(t, CacheHit, cache)
+
+	assert.Empty(t, clock.waits)
+
+	// once stale, going upstream again waits for a token
+	clock.now = clock.now.Add(2 * time.Minute)
+	_, cache, err = c.getJSON(ctx, "https://api.weather.gov/gridpoints/SJU/107,106/forecast", &forecast)
+	require.NoError(t, err)
+	assert.Equal(t, CacheMiss, cache)
+	assert.Equal(t, 2, calls)
+	require.Len(t, clock.waits, 1)
+	assert.True(t, clock.waits[0] > time.Hour)
+
+	// the in-flight slot was freed after each request
+	assert.Equal(t, 0, len(c.Limiter.slots))
+}
+
+func TestParseCacheControl(t *testing.T) {
+	assert.Equal(t, map[string]string{"public": "", "max-age": "60", "no-cache": "Set-Cookie"},
+		parseCacheControl(`public, Max-Age=60, no-cache="Set-Cookie"`))
+	assert.Empty(t, parseCacheControl(""))
+}
@@ -0,0 +1,190 @@
+package weather
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Limiter caps how hard clients sharing it press upstream: a token bucket
+// of requests per second and a maximum number of requests in flight. Set
+// the same *Limiter on every Client, or copy of one, that should share the
+// limits. A nil *Limiter imposes none.
+type Limiter struct {
+	rate  float64
+	burst float64
+
+	// slots holds a value for each request in flight
+	slots chan struct{}
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a Limiter allowing rate requests per second, with
+// bursts of up to burst requests, and at most maxInFlight requests at once.
+// A rate or maxInFlight of zero or less leaves that limit off. A burst below
+// one is taken as one.
+func NewLimiter(rate float64, burst, maxInFlight int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	l := &Limiter{rate: rate, burst: float64(burst), tokens: float64(burst)}
+
+	if maxInFlight > 0 {
+		l.slots = make(chan struct{}, maxInFlight)
+	}
+
+	return l
+}
+
+// acquire waits for an in-flight slot and then a token, measuring time on
+// clock. It returns a function that frees the slot once the request is done,
+// or ctx's error if ctx ends first or would end before a token is due.
+func (l *Limiter) acquire(ctx context.Context, clock Clock) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	release := func() {
+		if l.slots != nil {
+			<-l.slots
+		}
+	}
+
+	if err := l.take(ctx, clock); err != nil {
+		release()
+		return nil, err
+	}
+
+	return release, nil
+}
+
+// take takes a token from the bucket, waiting for one if it is empty.
+func (l *Limiter) take(ctx context.Context, clock Clock) error {
+	if l.rate <= 0 {
+		return nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	now := clock.Now()
+	wait := l.reserve(now)
+
+	if wait <= 0 {
+		return nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && now.Add(wait).After(deadline) {
+		l.cancel()
+		return context.DeadlineExceeded
+	}
+
+	select {
+	case <-clock.After(wait):
+		return nil
+	case <-ctx.Done():
+		l.cancel()
+		return ctx.Err()
+	}
+}
+
+// reserve takes a token, letting the bucket go below empty, and returns how
+// long until that token is due. Later callers queue behind it.
+func (l *Limiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.last.IsZero() && now.After(l.last) {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	if l.last.IsZero() || now.After(l.last) {
+		l.last = now
+	}
+
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// cancel returns a token reserved by a caller that gave up waiting.
+func (l *Limiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens++
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+}
+
+type limiterKey struct{}
+
+type requestLimit struct {
+	limiter *Limiter
+	clock   Clock
+}
+
+// withLimiter returns ctx carrying l, for a transport to take, measuring time
+// on clock, before each request it sends upstream.
+func withLimiter(ctx context.Context, l *Limiter, clock Clock) context.Context {
+	return context.WithValue(ctx, limiterKey{}, requestLimit{limiter: l, clock: clock})
+}
+
+// limitedRoundTrip sends req through transport once the Limiter carried by
+// its context, if any, allows. The in-flight slot is held until the response
+// body is closed.
+func limitedRoundTrip(transport http.RoundTripper, req *http.Request) (*http.Response, error) {
+	limit, ok := req.Context().Value(limiterKey{}).(requestLimit)
+	if !ok {
+		return transport.RoundTrip(req)
+	}
+
+	release, err := limit.limiter.acquire(req.Context(), limit.clock)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	res.Body = &releasingBody{ReadCloser: res.Body, release: release}
+	return res, nil
+}
+
+// releasingBody frees a Limiter slot when it is first closed.
+type releasingBody struct {
+	io.ReadCloser
+
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
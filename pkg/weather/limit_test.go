@@ -0,0 +1,183 @@
+package weather
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"sketch-go-course/pkg/location"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimiterRate(t *testing.T) {
+
+	clock := &fakeClock{now: time.Date(2020, 4, 24, 12, 0, 0, 0, time.UTC)}
+	l := NewLimiter(2, 2, 0)
+
+	for i := 0; i < 5; i++ {
+		release, err := l.acquire(context.Background(), clock)
+		require.NoError(t, err)
+		release()
+	}
+
+	// the burst goes at once, then one request every half second
+	assert.Equal(t, []time.Duration{500 * time.Millisecond, 500 * time.Millisecond, 500 * time.Millisecond}, clock.waits)
+
+	// a quiet spell refills the bucket, but only up to the burst
+	clock.now = clock.now.Add(time.Hour)
+	clock.waits = nil
+	for i := 0; i < 3; i++ {
+		release, err := l.acquire(context.Background(), clock)
+		require.NoError(t, err)
+		release()
+	}
+	assert.Equal(t, []time.Duration{500 * time.Millisecond}, clock.waits)
+}
+
+// stalledClock is a Clock whose waits never end. Each wait is announced on
+// waiting.
+type stalledClock struct {
+	now     time.Time
+	waiting chan time.Duration
+}
+
+func (c *stalledClock) Now() time.Time {
+	return c.now
+}
+
+func (c *stalledClock) After(d time.Duration) <-chan time.Time {
+	c.waiting <- d
+	return nil
+}
+
+func TestLimiterDeadline(t *testing.T) {
+
+	// the clock is well ahead of the real deadline, so the context does not
+	// end by itself during the test
+	clock := &fakeClock{now: time.Now().Add(time.Hour)}
+	l := NewLimiter(1, 1, 0)
+
+	release, err := l.acquire(context.Background(), clock)
+	require.NoError(t, err)
+	release()
+
+	// the next token is a second away, past the deadline, so it is not
+	// waited for
+	ctx, cancel := context.WithDeadline(context.Background(), clock.now.Add(100*time.Millisecond))
+	defer cancel()
+
+	_, err = l.acquire(ctx, clock)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Empty(t, clock.waits)
+
+	// the token given up is back for the next caller
+	assert.InDelta(t, 0, l.tokens, 0.01)
+}
+
+func TestLimiterCancelWhileWaitingForToken(t *testing.T) {
+
+	clock := &stalledClock{now: time.Date(2020, 4, 24, 12, 0, 0, 0, time.UTC), waiting: make(chan time.Duration)}
+	l := NewLimiter(0.01, 1, 0)
+
+	release, err := l.acquire(context.Background(), clock)
+	require.NoError(t, err)
+	release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := l.acquire(ctx, clock)
+		errs <- err
+	}()
+
+	assert.Equal(t, 100*time.Second, <-clock.waiting)
+	cancel()
+
+	assert.Equal(t, context.Canceled, <-errs)
+	assert.InDelta(t, 0, l.tokens, 0.01)
+}
+
+func TestLimiterMaxInFlight(t *testing.T) {
+
+	var mu sync.Mutex
+	inFlight, most := 0, 0
+
+	var overlapped sync.Once
+	both := make(chan struct{})
+
+	httpClient := &http.Client{
+		Transport: MockClient{
+			Fn: func(request *http.Request) (*http.Response, error) {
+				mu.Lock()
+				inFlight++
+				if inFlight > most {
+					most = inFlight
+				}
+				if inFlight == 2 {
+					overlapped.Do(func() { close(both) })
+				}
+				mu.Unlock()
+
+				// the first requests wait for each other, so two are
+				// certainly in flight at once
+				<-both
+
+				mu.Lock()
+				inFlight--
+				mu.Unlock()
+
+				body := mockResponse
+				if request.URL.Path == "/gridpoints/SJU/107,106/forecast" {
+					body = mockResponse2
+				}
+				return &http.Response{StatusCode: http.StatusOK, Request: request, Header: http.Header{}, Body: ioutil.NopCloser(bytes.NewReader([]byte(body)))}, nil
+			},
+		},
+	}
+
+	// clients are copied freely; the limiter they point to is shared
+	c := Client{Client: httpClient, Limiter: NewLimiter(0, 0, 2)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(c Client) {
+			defer wg.Done()
+			_, err := c.FetchForecast(location.Coordinate{Lat: 38.676026, Long: -90.377994})
+			assert.NoError(t, err)
+		}(c)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 2, most)
+	assert.Equal(t, 0, len(c.Limiter.slots))
+}
+
+func TestFetchForecastCancelWhileWaitingForSlot(t *testing.T) {
+
+	l := NewLimiter(0, 0, 1)
+
+	// hold the only slot
+	release, err := l.acquire(context.Background(), realClock{})
+	require.NoError(t, err)
+	defer release()
+
+	started := make(chan string, 10)
+	c := Client{Client: stallingClient("", started), Limiter: l, Retry: DefaultRetryPolicy}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = c.FetchForecastContext(ctx, location.Coordinate{Lat: 38.676026, Long: -90.377994})
+	assert.True(t, errors.Is(err, ErrNetwork))
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	// the request gave up waiting for the slot rather than being sent
+	assert.Empty(t, started)
+}
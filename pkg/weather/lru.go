@@ -0,0 +1,99 @@
+package weather
+
+import (
+	"container/list"
+)
+
+// lru maps keys to values, remembering the order they were last used in so
+// the least recently used can be dropped first. The zero value is ready to
+// use. It is not safe for concurrent use; its owners lock around it.
+type lru struct {
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type lruItem struct {
+	key   string
+	value interface{}
+}
+
+// peek returns the value under key without counting it as used.
+func (l *lru) peek(key string) (interface{}, bool) {
+	element, ok := l.entries[key]
+	if !ok {
+		return nil, false
+	}
+	return element.Value.(*lruItem).value, true
+}
+
+// get returns the value under key and makes it the most recently used.
+func (l *lru) get(key string) (interface{}, bool) {
+	element, ok := l.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	l.order.MoveToFront(element)
+	return element.Value.(*lruItem).value, true
+}
+
+// put adds or replaces the value under key and makes it the most recently
+// used.
+func (l *lru) put(key string, value interface{}) {
+	if element, ok := l.entries[key]; ok {
+		element.Value.(*lruItem).value = value
+		l.order.MoveToFront(element)
+		return
+	}
+
+	if l.entries == nil {
+		l.order, l.entries = list.New(), make(map[string]*list.Element)
+	}
+	l.entries[key] = l.order.PushFront(&lruItem{key: key, value: value})
+}
+
+// remove drops the value under key, reporting whether there was one.
+func (l *lru) remove(key string) bool {
+	element, ok := l.entries[key]
+	if !ok {
+		return false
+	}
+
+	l.order.Remove(element)
+	delete(l.entries, key)
+	return true
+}
+
+// oldest returns the least recently used key and its value.
+func (l *lru) oldest() (string, interface{}, bool) {
+	if l.order == nil || l.order.Len() == 0 {
+		return "", nil, false
+	}
+
+	item := l.order.Back().Value.(*lruItem)
+	return item.key, item.value, true
+}
+
+// trim drops the least recently used values past size, returning how many
+// it dropped.
+func (l *lru) trim(size int) int {
+	dropped := 0
+	for l.len() > size {
+		key, _, _ := l.oldest()
+		l.remove(key)
+		dropped++
+	}
+	return dropped
+}
+
+// each calls fn with every key and value, in no particular order. fn must
+// not change l.
+func (l *lru) each(fn func(key string, value interface{})) {
+	for key, element := range l.entries {
+		fn(key, element.Value.(*lruItem).value)
+	}
+}
+
+func (l *lru) len() int {
+	return len(l.entries)
+}
@@ -0,0 +1,51 @@
+package weather
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLRU(t *testing.T) {
+
+	var l lru
+
+	_, _, ok := l.oldest()
+	assert.False(t, ok)
+	assert.Equal(t, 0, l.trim(0))
+
+	l.put("a", 1)
+	l.put("b", 2)
+	l.put("c", 3)
+
+	// peek leaves the order alone, get makes a key the most recently used
+	value, ok := l.peek("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+
+	key, _, _ := l.oldest()
+	assert.Equal(t, "a", key)
+
+	l.get("a")
+	key, _, _ = l.oldest()
+	assert.Equal(t, "b", key)
+
+	// replacing a value counts as using it
+	l.put("b", 20)
+	key, value, _ = l.oldest()
+	assert.Equal(t, "c", key)
+	assert.Equal(t, 3, value)
+
+	assert.Equal(t, 1, l.trim(2))
+	_, ok = l.get("c")
+	assert.False(t, ok)
+	assert.Equal(t, 2, l.len())
+
+	assert.True(t, l.remove("a"))
+	assert.False(t, l.remove("a"))
+
+	seen := map[string]interface{}{}
+	l.each(func(key string, value interface{}) {
+		seen[key] = value
+	})
+	assert.Equal(t, map[string]interface{}{"b": 20}, seen)
+}
@@ -0,0 +1,340 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultPointsTTL is how long a Client trusts a cached grid point when it
+// does not set PointsTTL. Grid points change only when upstream redraws its
+// forecast areas, which is rare.
+const DefaultPointsTTL = 24 * time.Hour
+
+// PointsEntry is a cached /points/ answer: the forecast URL for a point and
+// when the entry stops being trusted.
+type PointsEntry struct {
+	ForecastURL string    `json:"forecast"`
+	Expires     time.Time `json:"expires"`
+}
+
+// PointsCache stores the forecast URL for each /points/ URL, so a Client can
+// skip the /points/ request for points it has seen. Keys are canonical
+// /points/ URLs. The Client checks expiry itself, so a cache may return
+// expired entries. Implementations must be safe for concurrent use.
+type PointsCache interface {
+	Get(key string) (PointsEntry, bool)
+	Put(key string, entry PointsEntry) error
+	Delete(key string) error
+}
+
+var (
+	_ PointsCache = (*LRUPointsCache)(nil)
+	_ PointsCache = (*FilePointsCache)(nil)
+)
+
+// LRUPointsCache keeps up to a fixed number of entries in memory, dropping
+// the least recently used when full.
+type LRUPointsCache struct {
+	size int
+
+	mu      sync.Mutex
+	entries lru
+}
+
+// NewLRUPointsCache returns an empty cache holding at most size entries, or
+// one if size is less.
+func NewLRUPointsCache(size int) *LRUPointsCache {
+	if size < 1 {
+		size = 1
+	}
+	return &LRUPointsCache{size: size}
+}
+
+func (c *LRUPointsCache) Get(key string) (PointsEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries.get(key)
+	if !ok {
+		return PointsEntry{}, false
+	}
+	return entry.(PointsEntry), true
+}
+
+func (c *LRUPointsCache) Put(key string, entry PointsEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries.put(key, entry)
+	c.entries.trim(c.size)
+
+	return nil
+}
+
+func (c *LRUPointsCache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries.remove(key)
+	return nil
+}
+
+// Len is the number of entries in the cache.
+func (c *LRUPointsCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.entries.len()
+}
+
+// DefaultPointsEntries is how many entries a FilePointsCache keeps when
+// opened without a size.
+const DefaultPointsEntries = 10000
+
+// FilePointsCache keeps entries in a JSON file so they outlast the process.
+// Changes are held in memory until Flush, which replaces the file whole so a
+// crash never leaves it half written. Past its size the least recently used
+// entries are dropped. Expired entries are dropped when the cache is opened
+// and flushed, and sooner if they are also the least recently used.
+type FilePointsCache struct {
+	// Clock, if set, is what expiry is checked against.
+	Clock Clock
+
+	filename string
+	size     int
+
+	// flushing keeps flushes in order, so an older snapshot never replaces
+	// a newer one
+	flushing sync.Mutex
+
+	mu      sync.Mutex
+	entries lru
+	dirty   bool
+}
+
+// OpenFilePointsCache loads the cache in filename, starting empty if the file
+// does not exist yet, to hold at most size entries, or DefaultPointsEntries
+// if size is zero or less. Entries already expired by now are dropped, and
+// past size those expiring soonest.
+func OpenFilePointsCache(filename string, size int, now time.Time) (*FilePointsCache, error) {
+	if size <= 0 {
+		size = DefaultPointsEntries
+	}
+
+	c := &FilePointsCache{filename: filename, size: size}
+
+	data, err := ioutil.ReadFile(filename)
+
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var saved map[string]PointsEntry
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(saved))
+	for key, entry := range saved {
+		if now.Before(entry.Expires) {
+			keys = append(keys, key)
+		}
+	}
+
+	// the file does not record use, so the longest lived count as most
+	// recently used
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := saved[keys[i]].Expires, saved[keys[j]].Expires
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return keys[i] < keys[j]
+	})
+
+	for _, key := range keys {
+		c.entries.put(key, saved[key])
+	}
+	c.trim()
+
+	return c, nil
+}
+
+func (c *FilePointsCache) clock() Clock {
+	if c.Clock == nil {
+		return realClock{}
+	}
+	return c.Clock
+}
+
+func (c *FilePointsCache) Get(key string) (PointsEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries.get(key)
+	if !ok {
+		return PointsEntry{}, false
+	}
+	return entry.(PointsEntry), true
+}
+
+func (c *FilePointsCache) Put(key string, entry PointsEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if old, ok := c.entries.get(key); ok {
+		if old.(PointsEntry) != entry {
+			c.entries.put(key, entry)
+			c.dirty = true
+		}
+		return nil
+	}
+
+	// the least recently used are dropped first anyway, so only those need
+	// checking
+	now := c.clock().Now()
+	for {
+		oldestKey, oldest, ok := c.entries.oldest()
+		if !ok || now.Before(oldest.(PointsEntry).Expires) {
+			break
+		}
+		c.entries.remove(oldestKey)
+	}
+
+	c.entries.put(key, entry)
+	c.trim()
+	c.dirty = true
+
+	return nil
+}
+
+func (c *FilePointsCache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries.remove(key) {
+		c.dirty = true
+	}
+	return nil
+}
+
+// Len is the number of entries in the cache.
+func (c *FilePointsCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.entries.len()
+}
+
+// trim drops the least recently used entries past the cache's size. The
+// caller holds c.mu.
+func (c *FilePointsCache) trim() {
+	if c.entries.trim(c.size) > 0 {
+		c.dirty = true
+	}
+}
+
+// Flush writes the unexpired entries to the file if they changed since it
+// was last written, dropping the expired ones. The file is written without
+// holding up Get and Put.
+func (c *FilePointsCache) Flush() error {
+	c.flushing.Lock()
+	defer c.flushing.Unlock()
+
+	c.mu.Lock()
+	if !c.dirty {
+		c.mu.Unlock()
+		return nil
+	}
+
+	now := c.clock().Now()
+	entries := make(map[string]PointsEntry, c.entries.len())
+	var expired []string
+	c.entries.each(func(key string, value interface{}) {
+		entry := value.(PointsEntry)
+		if !now.Before(entry.Expires) {
+			expired = append(expired, key)
+			return
+		}
+		entries[key] = entry
+	})
+	for _, key := range expired {
+		c.entries.remove(key)
+	}
+	c.dirty = false
+	c.mu.Unlock()
+
+	if err := c.save(entries); err != nil {
+		c.mu.Lock()
+		c.dirty = true
+		c.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+// FlushEvery calls Flush every interval until ctx is done, and once more
+// then. onFlush, if not nil, is called with every error.
+func (c *FilePointsCache) FlushEvery(ctx context.Context, interval time.Duration, onFlush func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		var done bool
+
+		select {
+		case <-ctx.Done():
+			done = true
+		case <-ticker.C:
+		}
+
+		if err := c.Flush(); err != nil && onFlush != nil {
+			onFlush(err)
+		}
+
+		if done {
+			return
+		}
+	}
+}
+
+// save writes entries to a temporary file beside the cache file and renames
+// it into place.
+func (c *FilePointsCache) save(entries map[string]PointsEntry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(c.filename), filepath.Base(c.filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	if err := os.Rename(f.Name(), c.filename); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,233 @@
+package weather
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sketch-go-course/pkg/location"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLRUPointsCache(t *testing.T) {
+
+	c := NewLRUPointsCache(2)
+
+	require.NoError(t, c.Put("a", PointsEntry{ForecastURL: "A"}))
+	require.NoError(t, c.Put("b", PointsEntry{ForecastURL: "B"}))
+
+	// using a makes b the least recently used
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+
+	require.NoError(t, c.Put("c", PointsEntry{ForecastURL: "C"}))
+	assert.Equal(t, 2, c.Len())
+
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+
+	entry, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "A", entry.ForecastURL)
+
+	require.NoError(t, c.Put("a", PointsEntry{ForecastURL: "A2"}))
+	entry, _ = c.Get("a")
+	assert.Equal(t, "A2", entry.ForecastURL)
+	assert.Equal(t, 2, c.Len())
+
+	require.NoError(t, c.Delete("a"))
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 1, c.Len())
+}
+
+func TestFilePointsCache(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "points")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "points.json")
+	now := time.Date(2020, 4, 24, 12, 0, 0, 0, time.UTC)
+
+	c, err := OpenFilePointsCache(filename, 0, now)
+	require.NoError(t, err)
+	c.Clock = &fakeClock{now: now}
+	assert.Equal(t, 0, c.Len())
+
+	require.NoError(t, c.Put("fresh", PointsEntry{ForecastURL: "F", Expires: now.Add(time.Hour)}))
+	require.NoError(t, c.Put("stale", PointsEntry{ForecastURL: "S", Expires: now.Add(time.Minute)}))
+	require.NoError(t, c.Put("gone", PointsEntry{ForecastURL: "G", Expires: now.Add(time.Hour)}))
+	require.NoError(t, c.Delete("gone"))
+
+	// nothing is written until the cache is flushed
+	_, err = os.Stat(filename)
+	assert.True(t, os.IsNotExist(err))
+	require.NoError(t, c.Flush())
+
+	// reopened later, the stale entry has expired
+	reopened, err := OpenFilePointsCache(filename, 0, now.Add(30*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, reopened.Len())
+
+	entry, ok := reopened.Get("fresh")
+	assert.True(t, ok)
+	assert.Equal(t, "F", entry.ForecastURL)
+	assert.True(t, entry.Expires.Equal(now.Add(time.Hour)))
+
+	// no temporary files are left behind
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	require.NoError(t, ioutil.WriteFile(filename, []byte("not json"), 0644))
+	_, err = OpenFilePointsCache(filename, 0, now)
+	assert.Error(t, err)
+}
+
+func TestFilePointsCacheBounded(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "points")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "points.json")
+	clock := &fakeClock{now: time.Date(2020, 4, 24, 12, 0, 0, 0, time.UTC)}
+
+	c, err := OpenFilePointsCache(filename, 2, clock.now)
+	require.NoError(t, err)
+	c.Clock = clock
+
+	require.NoError(t, c.Put("a", PointsEntry{ForecastURL: "A", Expires: clock.now.Add(time.Hour)}))
+	require.NoError(t, c.Put("b", PointsEntry{ForecastURL: "B", Expires: clock.now.Add(2 * time.Hour)}))
+
+	// using a makes b the least recently used
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+
+	require.NoError(t, c.Put("c", PointsEntry{ForecastURL: "C", Expires: clock.now.Add(2 * time.Hour)}))
+	assert.Equal(t, 2, c.Len())
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+
+	// once a has expired, adding d drops it rather than c
+	clock.now = clock.now.Add(90 * time.Minute)
+	require.NoError(t, c.Put("d", PointsEntry{ForecastURL: "D", Expires: clock.now.Add(time.Hour)}))
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	_, ok = c.Get("c")
+	assert.True(t, ok)
+
+	// flushing drops the entries expired by then
+	clock.now = clock.now.Add(45 * time.Minute)
+	require.NoError(t, c.Flush())
+	assert.Equal(t, 1, c.Len())
+	_, ok = c.Get("d")
+	assert.True(t, ok)
+
+	// a cache flushed with nothing changed leaves the file alone
+	require.NoError(t, ioutil.WriteFile(filename, []byte("{}"), 0644))
+	require.NoError(t, c.Flush())
+	data, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, "{}", string(data))
+
+	// reopening keeps the longest lived entries past the size
+	saved := make(map[string]PointsEntry)
+	for i := 0; i < 10; i++ {
+		saved[strconv.Itoa(i)] = PointsEntry{ForecastURL: "F", Expires: clock.now.Add(time.Duration(i+1) * time.Minute)}
+	}
+	data, err = json.Marshal(saved)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filename, data, 0644))
+
+	reopened, err := OpenFilePointsCache(filename, 5, clock.now)
+	require.NoError(t, err)
+	assert.Equal(t, 5, reopened.Len())
+	_, ok = reopened.Get("4")
+	assert.False(t, ok)
+	_, ok = reopened.Get("5")
+	assert.True(t, ok)
+
+	reopened, err = OpenFilePointsCache(filename, 20, clock.now)
+	require.NoError(t, err)
+	assert.Equal(t, 10, reopened.Len())
+}
+
+// gridClient answers /points/ with mockResponse, pointing at forecastPath, and
+// forecastPath with mockResponse2. Other paths are not found. It counts each
+// kind of request.
+func gridClient(forecastPath *string, points, forecasts *int) *http.Client {
+	return &http.Client{
+		Transport: MockClient{
+			Fn: func(request *http.Request) (*http.Response, error) {
+				respond := func(status int, body string) (*http.Response, error) {
+					return &http.Response{StatusCode: status, Request: request, Header: http.Header{}, Body: ioutil.NopCloser(bytes.NewReader([]byte(body)))}, nil
+				}
+
+				switch {
+				case len(request.URL.Path) > 8 && request.URL.Path[:8] == "/points/":
+					*points++
+					return respond(http.StatusOK, string(bytes.Replace([]byte(mockResponse), []byte("/gridpoints/SJU/107,106/forecast\""), []byte(*forecastPath+"\""), 1)))
+				case request.URL.Path == *forecastPath:
+					*forecasts++
+					return respond(http.StatusOK, mockResponse2)
+				}
+				return respond(http.StatusNotFound, "")
+			},
+		},
+	}
+}
+
+func TestFetchForecastPointsCache(t *testing.T) {
+
+	coordinate := location.Coordinate{Lat: 38.676026, Long: -90.377994}
+	nearby := location.Coordinate{Lat: 38.676049, Long: -90.377951}
+
+	forecastPath := "/gridpoints/SJU/107,106/forecast"
+	points, forecasts := 0, 0
+	clock := &fakeClock{now: time.Date(2020, 4, 24, 12, 0, 0, 0, time.UTC)}
+
+	c := Client{
+		Client:    gridClient(&forecastPath, &points, &forecasts),
+		Precision: DefaultPrecision,
+		Clock:     clock,
+		Points:    NewLRUPointsCache(10),
+		PointsTTL: time.Hour,
+	}
+
+	_, err := c.FetchForecast(coordinate)
+	require.NoError(t, err)
+
+	// the same point, and a point rounding to it, skip /points/
+	_, err = c.FetchForecast(coordinate)
+	require.NoError(t, err)
+	_, err = c.FetchForecast(nearby)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, points)
+	assert.Equal(t, 3, forecasts)
+
+	// once the entry expires /points/ is asked again
+	clock.now = clock.now.Add(2 * time.Hour)
+	_, err = c.FetchForecast(coordinate)
+	require.NoError(t, err)
+	assert.Equal(t, 2, points)
+
+	// a redrawn grid point makes the cached forecast URL not found
+	forecastPath = "/gridpoints/SJU/108,106/forecast"
+	forecast, err := c.FetchForecast(coordinate)
+	require.NoError(t, err)
+	assert.Len(t, forecast.Properties.Periods, 14)
+	assert.Equal(t, 3, points)
+
+	entry, ok := c.Points.Get(pointsURL + "38.676,-90.378")
+	assert.True(t, ok)
+	assert.Equal(t, "https://api.weather.gov"+forecastPath, entry.ForecastURL)
+}
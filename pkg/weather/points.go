@@ -0,0 +1,127 @@
+package weather
+
+import (
+	"math"
+	"sketch-go-course/pkg/location"
+	"sync"
+)
+
+const pointsURL = "https://api.weather.gov/points/"
+
+// DefaultPrecision is the number of decimal places clients should send
+// coordinates to /points/ with. Upstream redirects requests with more than
+// four to the rounded point.
+const DefaultPrecision = 4
+
+// NoRounding as a Client's Precision sends coordinates as they are.
+const NoRounding = -1
+
+// Rounding is how a Client drops the digits past its precision.
+type Rounding int
+
+const (
+	// RoundNearest rounds half away from zero, as upstream does.
+	RoundNearest Rounding = iota
+	// RoundTruncate drops the extra digits, rounding toward zero.
+	RoundTruncate
+)
+
+// Canonical returns coordinate as the client sends it upstream: rounded to
+// Precision decimal places with Rounding.
+func (c Client) Canonical(coordinate location.Coordinate) location.Coordinate {
+	if c.Precision < 0 {
+		return coordinate
+	}
+
+	scale := math.Pow(10, float64(c.Precision))
+	round := math.Round
+	if c.Rounding == RoundTruncate {
+		round = math.Trunc
+	}
+
+	return location.Coordinate{
+		Lat:  round(coordinate.Lat*scale) / scale,
+		Long: round(coordinate.Long*scale) / scale,
+	}
+}
+
+// PointsURL is the /points/ URL for coordinate after canonicalizing it, or
+// where upstream last redirected that URL if the client records Redirects.
+// Points that share a URL share a grid point, so it serves as a cache key.
+func (c Client) PointsURL(coordinate location.Coordinate) string {
+	url := pointsURL + c.Canonical(coordinate).String()
+
+	if target, ok := c.Redirects.Target(url); ok {
+		return target
+	}
+	return url
+}
+
+// DefaultRedirects is how many redirects a Redirects keeps when MaxEntries
+// is not set.
+const DefaultRedirects = 10000
+
+// Redirects remembers where upstream redirected /points/ requests so later
+// requests go straight to the target. It keeps at most MaxEntries, dropping
+// the least recently used. The zero value is ready to use, a nil *Redirects
+// records nothing, and it is safe for concurrent use.
+type Redirects struct {
+	// MaxEntries caps how many redirects are kept. Zero means
+	// DefaultRedirects.
+	MaxEntries int
+
+	mu      sync.Mutex
+	targets lru
+}
+
+// Target returns where url was redirected to, if it was.
+func (r *Redirects) Target(url string) (string, bool) {
+	if r == nil {
+		return "", false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	target, ok := r.targets.get(url)
+	if !ok {
+		return "", false
+	}
+	return target.(string), true
+}
+
+// Record notes that url redirected to target. Recording a url as its own
+// target forgets it.
+func (r *Redirects) Record(url, target string) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if url == target {
+		r.targets.remove(url)
+		return
+	}
+
+	r.targets.put(url, target)
+
+	max := r.MaxEntries
+	if max <= 0 {
+		max = DefaultRedirects
+	}
+	r.targets.trim(max)
+}
+
+// Len is the number of redirects recorded.
+func (r *Redirects) Len() int {
+	if r == nil {
+		return 0
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.targets.len()
+}
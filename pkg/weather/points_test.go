@@ -0,0 +1,124 @@
+package weather
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"sketch-go-course/pkg/location"
+	"testing"
+)
+
+func TestCanonical(t *testing.T) {
+
+	c := location.Coordinate{Lat: 38.676026, Long: -90.377994}
+
+	assert.Equal(t, "38.676,-90.378", Client{Precision: DefaultPrecision}.Canonical(c).String())
+	assert.Equal(t, "39,-90", Client{}.Canonical(c).String())
+	assert.Equal(t, "38.68,-90.38", Client{Precision: 2}.Canonical(c).String())
+	assert.Equal(t, "38.67,-90.37", Client{Precision: 2, Rounding: RoundTruncate}.Canonical(c).String())
+	assert.Equal(t, "38.676026,-90.377994", Client{Precision: NoRounding}.Canonical(c).String())
+
+	// nearby ZIP centroids share a grid point request
+	near := location.Coordinate{Lat: 38.67596, Long: -90.37803}
+	assert.Equal(t, Client{Precision: DefaultPrecision}.PointsURL(c), Client{Precision: DefaultPrecision}.PointsURL(near))
+}
+
+func TestFetchForecastRecordsRedirects(t *testing.T) {
+
+	const (
+		requested = "https://api.weather.gov/points/38.676026,-90.377994"
+		target    = "https://api.weather.gov/points/38.676,-90.378"
+	)
+
+	var requests []string
+
+	c := Client{
+		Precision: NoRounding,
+		Redirects: &Redirects{},
+		Client: &http.Client{
+			Transport: MockClient{
+				Fn: func(request *http.Request) (*http.Response, error) {
+
+					requests = append(requests, request.URL.String())
+					res := &http.Response{Request: request, Header: http.Header{}}
+					res.Body = ioutil.NopCloser(bytes.NewReader(nil))
+
+					switch request.URL.String() {
+					case requested:
+						res.StatusCode = http.StatusMovedPermanently
+						res.Header.Set("Location", target)
+					case target:
+						res.StatusCode = http.StatusOK
+						res.Body = ioutil.NopCloser(bytes.NewReader([]byte(mockResponse)))
+					default:
+						res.StatusCode = http.StatusOK
+						res.Body = ioutil.NopCloser(bytes.NewReader([]byte(mockResponse2)))
+					}
+					return res, nil
+				},
+			},
+		},
+	}
+
+	coordinate := location.Coordinate{Lat: 38.676026, Long: -90.377994}
+
+	_, err := c.FetchForecast(coordinate)
+	require.NoError(t, err)
+
+	got, ok := c.Redirects.Target(requested)
+	require.True(t, ok)
+	assert.Equal(t, target, got)
+	assert.Equal(t, target, c.PointsURL(coordinate))
+
+	// the second fetch goes straight to the target
+	requests = nil
+	forecast, err := c.FetchForecast(coordinate)
+	require.NoError(t, err)
+	assert.Len(t, forecast.Properties.Periods, 14)
+	assert.Equal(t, target, requests[0])
+	assert.Len(t, requests, 2)
+}
+
+func TestRedirectsNil(t *testing.T) {
+
+	var r *Redirects
+	r.Record("a", "b")
+
+	_, ok := r.Target("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, r.Len())
+
+	r = &Redirects{}
+	r.Record("a", "b")
+	assert.Equal(t, 1, r.Len())
+	r.Record("a", "a")
+	assert.Equal(t, 0, r.Len())
+}
+
+func TestRedirectsCapped(t *testing.T) {
+
+	r := &Redirects{MaxEntries: 2}
+	r.Record("a", "A")
+	r.Record("b", "B")
+
+	// using a makes b the least recently used
+	_, ok := r.Target("a")
+	assert.True(t, ok)
+
+	r.Record("c", "C")
+	assert.Equal(t, 2, r.Len())
+
+	_, ok = r.Target("b")
+	assert.False(t, ok)
+
+	target, ok := r.Target("a")
+	assert.True(t, ok)
+	assert.Equal(t, "A", target)
+
+	r.Record("a", "A2")
+	target, _ = r.Target("a")
+	assert.Equal(t, "A2", target)
+	assert.Equal(t, 2, r.Len())
+}
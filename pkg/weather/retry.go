@@ -0,0 +1,162 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// Clock is the time source the client waits on between retries. Tests can
+// substitute one that does not really sleep.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// RetryPolicy says when and how long to wait before repeating a failed
+// request. The zero value makes a single attempt.
+type RetryPolicy struct {
+	// MaxAttempts is the most times a request is made, counting the first.
+	MaxAttempts int
+
+	// BaseDelay is the wait before the first retry. Each later retry waits
+	// twice as long as the one before, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// Jitter, from 0 to 1, is how much of each wait may be randomly taken
+	// off, so clients that failed together do not retry together.
+	Jitter float64
+
+	// MaxRetryAfter is the longest Retry-After the client will honour. When
+	// upstream asks for a longer wait the error is returned instead. Zero
+	// means any wait is honoured.
+	MaxRetryAfter time.Duration
+
+	// Retryable decides whether a failed request may be repeated. Nil means
+	// DefaultRetryable.
+	Retryable func(method string, err error) bool
+
+	// Rand returns numbers in [0, 1) for jitter. Nil means math/rand.
+	Rand func() float64
+}
+
+// DefaultRetryPolicy makes up to three attempts, waiting around half a
+// second and then a second, and honours Retry-After up to thirty seconds.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:   3,
+	BaseDelay:     500 * time.Millisecond,
+	MaxDelay:      5 * time.Second,
+	Jitter:        0.5,
+	MaxRetryAfter: 30 * time.Second,
+}
+
+// DefaultRetryable allows retrying idempotent requests, GET and HEAD, that
+// failed in a way that may pass: rate limiting, 500, 502, 503 and 504
+// responses, and network failures other than the caller's context ending.
+// Not found, decode failures and other statuses would only fail again.
+func DefaultRetryable(method string, err error) bool {
+	if method != http.MethodGet && method != http.MethodHead {
+		return false
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var e *UpstreamError
+	if !errors.As(err, &e) {
+		return false
+	}
+
+	switch e.Kind {
+	case ErrRateLimited, ErrNetwork:
+		return true
+	case ErrServer:
+		switch e.StatusCode {
+		case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the wait before retry number n, counting from 1.
+func (p RetryPolicy) backoff(n int) time.Duration {
+	d := float64(p.BaseDelay) * math.Pow(2, float64(n-1))
+	if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+
+	if p.Jitter > 0 {
+		random := rand.Float64
+		if p.Rand != nil {
+			random = p.Rand
+		}
+		d -= d * math.Min(p.Jitter, 1) * random()
+	}
+
+	return time.Duration(d)
+}
+
+// delay returns how long to wait before retry number n after err, and
+// whether to retry at all.
+func (p RetryPolicy) delay(n int, method string, err error) (time.Duration, bool) {
+	if n >= p.MaxAttempts {
+		return 0, false
+	}
+
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = DefaultRetryable
+	}
+	if !retryable(method, err) {
+		return 0, false
+	}
+
+	d := p.backoff(n)
+
+	var e *UpstreamError
+	if errors.As(err, &e) && e.RetryAfter > 0 {
+		if p.MaxRetryAfter > 0 && e.RetryAfter > p.MaxRetryAfter {
+			return 0, false
+		}
+		if e.RetryAfter > d {
+			d = e.RetryAfter
+		}
+	}
+
+	return d, true
+}
+
+func (c Client) clock() Clock {
+	if c.Clock == nil {
+		return realClock{}
+	}
+	return c.Clock
+}
+
+// wait sleeps for d on the client's clock. It returns false without waiting
+// if ctx would end first, or early if ctx ends.
+func (c Client) wait(ctx context.Context, d time.Duration) bool {
+	clock := c.clock()
+
+	if deadline, ok := ctx.Deadline(); ok && clock.Now().Add(d).After(deadline) {
+		return false
+	}
+
+	select {
+	case <-clock.After(d):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
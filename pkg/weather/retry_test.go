@@ -0,0 +1,212 @@
+package weather
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"sketch-go-course/pkg/location"
+	"testing"
+	"time"
+)
+
+// fakeClock records the waits asked of it and returns at once.
+type fakeClock struct {
+	now   time.Time
+	waits []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.waits = append(c.waits, d)
+	c.now = c.now.Add(d)
+
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+type scriptedResponse struct {
+	status int
+	header http.Header
+	err    error
+}
+
+// scriptedClient answers /points/ requests from script in order, then with
+// mockResponse, and forecast requests with mockResponse2. It counts the
+// /points/ requests.
+func scriptedClient(script []scriptedResponse, calls *int) *http.Client {
+	return &http.Client{
+		Transport: MockClient{
+			Fn: func(request *http.Request) (*http.Response, error) {
+				if request.URL.Path == "/gridpoints/SJU/107,106/forecast" {
+					return &http.Response{StatusCode: http.StatusOK, Request: request, Header: http.Header{}, Body: ioutil.NopCloser(bytes.NewReader([]byte(mockResponse2)))}, nil
+				}
+
+				*calls++
+				if *calls > len(script) {
+					return &http.Response{StatusCode: http.StatusOK, Request: request, Header: http.Header{}, Body: ioutil.NopCloser(bytes.NewReader([]byte(mockResponse)))}, nil
+				}
+
+				step := script[*calls-1]
+				if step.err != nil {
+					return nil, step.err
+				}
+				header := step.header
+				if header == nil {
+					header = http.Header{}
+				}
+				return &http.Response{StatusCode: step.status, Request: request, Header: header, Body: ioutil.NopCloser(bytes.NewReader(nil))}, nil
+			},
+		},
+	}
+}
+
+func TestFetchForecastRetries(t *testing.T) {
+
+	policy := RetryPolicy{
+		MaxAttempts:   4,
+		BaseDelay:     100 * time.Millisecond,
+		MaxDelay:      250 * time.Millisecond,
+		Jitter:        0.5,
+		MaxRetryAfter: time.Minute,
+		Rand:          func() float64 { return 0.5 },
+	}
+
+	coordinate := location.Coordinate{Lat: 38.676026, Long: -90.377994}
+
+	tests := map[string]struct {
+		script   []scriptedResponse
+		policy   RetryPolicy
+		calls    int
+		waits    []time.Duration
+		kind     error
+		attempts int
+	}{
+		"recovers after transient failures": {
+			script: []scriptedResponse{{status: 503}, {status: 500}, {err: errors.New("connection reset")}},
+			policy: policy,
+			calls:  4,
+			// 100ms, 200ms, then capped at 250ms, each less a quarter
+			waits: []time.Duration{75 * time.Millisecond, 150 * time.Millisecond, 187500 * time.Microsecond},
+		},
+		"honours retry-after": {
+			script: []scriptedResponse{{status: 429, header: http.Header{"Retry-After": []string{"7"}}}},
+			policy: policy,
+			calls:  2,
+			waits:  []time.Duration{7 * time.Second},
+		},
+		"honours retry-after dates on the clock": {
+			script: []scriptedResponse{{status: 503, header: http.Header{"Retry-After": []string{"Fri, 24 Apr 2020 12:00:09 GMT"}}}},
+			policy: policy,
+			calls:  2,
+			waits:  []time.Duration{9 * time.Second},
+		},
+		"gives up on a long retry-after": {
+			script:   []scriptedResponse{{status: 429, header: http.Header{"Retry-After": []string{"3600"}}}},
+			policy:   policy,
+			calls:    1,
+			kind:     ErrRateLimited,
+			attempts: 1,
+		},
+		"gives up after max attempts": {
+			script:   []scriptedResponse{{status: 502}, {status: 502}, {status: 502}, {status: 502}, {status: 502}},
+			policy:   policy,
+			calls:    4,
+			waits:    []time.Duration{75 * time.Millisecond, 150 * time.Millisecond, 187500 * time.Microsecond},
+			kind:     ErrServer,
+			attempts: 4,
+		},
+		"does not retry not found": {
+			script:   []scriptedResponse{{status: 404}},
+			policy:   policy,
+			calls:    1,
+			kind:     ErrNotFound,
+			attempts: 1,
+		},
+		"does not retry 501": {
+			script:   []scriptedResponse{{status: 501}},
+			policy:   policy,
+			calls:    1,
+			kind:     ErrServer,
+			attempts: 1,
+		},
+		"zero policy makes one attempt": {
+			script:   []scriptedResponse{{status: 503}},
+			calls:    1,
+			kind:     ErrServer,
+			attempts: 1,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+
+			clock := &fakeClock{now: time.Date(2020, 4, 24, 12, 0, 0, 0, time.UTC)}
+			calls := 0
+
+			c := Client{
+				Client: scriptedClient(test.script, &calls),
+				Retry:  test.policy,
+				Clock:  clock,
+			}
+
+			forecast, err := c.FetchForecast(coordinate)
+
+			assert.Equal(t, test.calls, calls)
+			assert.Equal(t, test.waits, clock.waits)
+
+			if test.kind == nil {
+				require.NoError(t, err)
+				assert.Len(t, forecast.Properties.Periods, 14)
+				return
+			}
+
+			assert.True(t, errors.Is(err, test.kind))
+			var e *UpstreamError
+			require.True(t, errors.As(err, &e))
+			assert.Equal(t, test.attempts, e.Attempts)
+		})
+	}
+}
+
+func TestRetryStopsAtDeadline(t *testing.T) {
+
+	clock := &fakeClock{now: time.Now()}
+	calls := 0
+
+	c := Client{
+		Client: scriptedClient([]scriptedResponse{{status: 429, header: http.Header{"Retry-After": []string{"20"}}}}, &calls),
+		Retry:  DefaultRetryPolicy,
+		Clock:  clock,
+	}
+
+	// waiting 20s would pass the deadline, so the 429 is returned at once
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := c.FetchForecastContext(ctx, location.Coordinate{Lat: 38.676026, Long: -90.377994})
+	assert.True(t, errors.Is(err, ErrRateLimited))
+	assert.Equal(t, 1, calls)
+	assert.Empty(t, clock.waits)
+}
+
+func TestDefaultRetryable(t *testing.T) {
+
+	network := &UpstreamError{Kind: ErrNetwork, Err: errors.New("connection refused")}
+	cancelled := &UpstreamError{Kind: ErrNetwork, Err: context.Canceled}
+	server := &UpstreamError{Kind: ErrServer, StatusCode: http.StatusServiceUnavailable}
+
+	assert.True(t, DefaultRetryable(http.MethodGet, network))
+	assert.True(t, DefaultRetryable(http.MethodHead, server))
+	assert.False(t, DefaultRetryable(http.MethodPost, server))
+	assert.False(t, DefaultRetryable(http.MethodGet, cancelled))
+	assert.False(t, DefaultRetryable(http.MethodGet, &UpstreamError{Kind: ErrDecode}))
+	assert.False(t, DefaultRetryable(http.MethodGet, errors.New("something else")))
+}
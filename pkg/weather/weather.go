@@ -1,11 +1,13 @@
 package weather
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"sketch-go-course/pkg/location"
+	"sort"
 	"time"
 )
 
@@ -13,20 +15,36 @@ type Forecast struct {
 	Properties struct {
 		Periods []Period
 	}
+
+	// Cache says whether the forecast came from the client's CacheTransport,
+	// if it has one.
+	Cache CacheStatus `json:"-"`
 }
 
 func (f Forecast) Summary() ForecastSummary {
+	return f.SummaryIn(nil)
+}
+
+// SummaryIn groups the forecast periods into days as seen in loc, so a
+// period starting late in the evening upstream lands on the right local day.
+// A nil loc keeps the offset each period was reported with.
+func (f Forecast) SummaryIn(loc *time.Location) ForecastSummary {
 
 	daysMap := make(map[string]ForecastDay)
 
 	for _, p := range f.Properties.Periods {
 
-		weekday := p.StartTime.Weekday().String()
+		startTime := p.StartTime
+		if loc != nil {
+			startTime = startTime.In(loc)
+		}
+
+		weekday := startTime.Weekday().String()
 
 		day, ok := daysMap[weekday]
 		if !ok {
 			daysMap[weekday] = ForecastDay{
-				Day:           p.StartTime,
+				Day:           startTime,
 				Low:           p.Temperature,
 				High:          p.Temperature,
 				ShortForecast: p.ShortForecast,
@@ -61,6 +79,27 @@ type ForecastSummary struct {
 	Days []ForecastDay
 }
 
+// Feature returns the summary as a GeoJSON point feature at c, with the days
+// in order under a "days" property alongside any extra properties given.
+func (s ForecastSummary) Feature(c location.Coordinate, properties map[string]interface{}) location.Feature {
+
+	days := make([]ForecastDay, len(s.Days))
+	copy(days, s.Days)
+
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Day.Before(days[j].Day)
+	})
+
+	feature := location.NewPointFeature(c, nil)
+
+	for name, value := range properties {
+		feature.Properties[name] = value
+	}
+	feature.Properties["days"] = days
+
+	return feature
+}
+
 type ForecastDay struct {
 	Day           time.Time
 	Low           float64
@@ -108,37 +147,225 @@ func (p *Period) UnmarshalJSON(data []byte) error {
 
 type Client struct {
 	Client *http.Client
+
+	// Precision is how many decimal places coordinates are rounded to
+	// before asking for their grid point, zero for whole degrees, or
+	// NoRounding to send them unchanged. Rounding says how. Most clients
+	// want DefaultPrecision.
+	Precision int
+	Rounding  Rounding
+
+	// Redirects, if set, records where /points/ requests were redirected
+	// so later requests for the same point skip the redirect.
+	Redirects *Redirects
+
+	// Timeout, if set, limits each FetchForecast call as a whole, both the
+	// /points/ and the forecast request. Unlike http.Client.Timeout it does
+	// not restart for the second request.
+	Timeout time.Duration
+
+	// Retry says which failed requests to repeat and how long to wait in
+	// between; the zero value never retries. Clock, if set, is what the
+	// waits and Retry-After dates are measured on.
+	Retry RetryPolicy
+	Clock Clock
+
+	// Limiter, if set, limits the rate and concurrency of upstream requests,
+	// retries included, across every client sharing it. Waiting for it ends
+	// with the request's context. Responses a CacheTransport answers without
+	// asking upstream are not counted.
+	Limiter *Limiter
+
+	// Points, if set, caches the forecast URL for each point so repeat
+	// lookups skip the /points/ request. Entries are trusted for PointsTTL,
+	// or DefaultPointsTTL if that is zero.
+	Points    PointsCache
+	PointsTTL time.Duration
 }
 
+// FetchForecast is FetchForecastContext with a background context.
 func (c Client) FetchForecast(coordinates location.Coordinate) (Forecast, error) {
+	return c.FetchForecastContext(context.Background(), coordinates)
+}
+
+// FetchForecastContext looks up the forecast grid point for coordinates and
+// fetches its forecast. Cancelling ctx, or passing its deadline or the
+// client's Timeout, aborts whichever request is in flight. Errors are
+// *UpstreamError values; see ErrNotFound and the other kinds.
+func (c Client) FetchForecastContext(ctx context.Context, coordinates location.Coordinate) (Forecast, error) {
+
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	canonicalURL := pointsURL + c.Canonical(coordinates).String()
+
+	forecastURL, cached := c.cachedForecastURL(canonicalURL)
+
+	if !cached {
+		var err error
+		if forecastURL, err = c.fetchForecastURL(ctx, coordinates, canonicalURL); err != nil {
+			return Forecast{}, err
+		}
+	}
+
+	var forecast Forecast
+	_, cache, err := c.getJSON(ctx, forecastURL, &forecast)
+
+	if err != nil && cached && errors.Is(err, ErrNotFound) {
+		// the grid point was redrawn since it was cached, so look it up again
+		_ = c.Points.Delete(canonicalURL)
+
+		if forecastURL, err = c.fetchForecastURL(ctx, coordinates, canonicalURL); err != nil {
+			return Forecast{}, err
+		}
+		_, cache, err = c.getJSON(ctx, forecastURL, &forecast)
+	}
+
+	if err != nil {
+		return Forecast{}, err
+	}
+
+	forecast.Cache = cache
+	return forecast, nil
+}
+
+// cachedForecastURL returns the forecast URL cached for canonicalURL, if the
+// client has a Points cache holding an entry that has not expired.
+func (c Client) cachedForecastURL(canonicalURL string) (string, bool) {
+	if c.Points == nil {
+		return "", false
+	}
+
+	entry, ok := c.Points.Get(canonicalURL)
+	if !ok || entry.ForecastURL == "" || !c.clock().Now().Before(entry.Expires) {
+		return "", false
+	}
+	return entry.ForecastURL, true
+}
+
+// fetchForecastURL asks /points/ for the forecast URL of coordinates,
+// recording any redirect and caching the answer under canonicalURL.
+func (c Client) fetchForecastURL(ctx context.Context, coordinates location.Coordinate, canonicalURL string) (string, error) {
+
+	var points Points
+	finalURL, _, err := c.getJSON(ctx, c.PointsURL(coordinates), &points)
+
+	if err != nil {
+		return "", err
+	}
+
+	// the client followed any redirects, so this is where we ended up
+	c.Redirects.Record(canonicalURL, finalURL)
+
+	if points.Properties.ForecastURL == "" {
+		return "", &UpstreamError{Kind: ErrDecode, URL: finalURL, StatusCode: http.StatusOK, Err: errors.New("no forecast URL in points response")}
+	}
+
+	if c.Points != nil {
+		ttl := c.PointsTTL
+		if ttl <= 0 {
+			ttl = DefaultPointsTTL
+		}
+
+		// a cache that cannot be written to only costs a /points/ request
+		_ = c.Points.Put(canonicalURL, PointsEntry{ForecastURL: points.Properties.ForecastURL, Expires: c.clock().Now().Add(ttl)})
+	}
+
+	return points.Properties.ForecastURL, nil
+}
+
+// getJSON fetches url and decodes its body into v, returning the URL the
+// response finally came from after redirects and its cache status. Failures
+// are retried as the client's Retry policy allows; if ctx ends while waiting
+// to retry, the last failure is returned.
+func (c Client) getJSON(ctx context.Context, url string, v interface{}) (string, CacheStatus, error) {
+
+	for attempt := 1; ; attempt++ {
+		finalURL, cache, err := c.getJSONOnce(ctx, url, v)
+
+		if err == nil {
+			return finalURL, cache, nil
+		}
+
+		var upstreamErr *UpstreamError
+		if errors.As(err, &upstreamErr) {
+			upstreamErr.Attempts = attempt
+		}
+
+		delay, retry := c.Retry.delay(attempt, http.MethodGet, err)
+
+		if !retry || !c.wait(ctx, delay) {
+			return finalURL, cache, err
+		}
+	}
+}
+
+func (c Client) getJSONOnce(ctx context.Context, url string, v interface{}) (string, CacheStatus, error) {
 
 	httpClient := c.Client
 
-	if res, getErr := httpClient.Get("https://api.weather.gov/points/" + coordinates.String()); getErr != nil {
-		fmt.Println("error calling GET", getErr)
-		return Forecast{}, getErr
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
 
-	} else {
-		fmt.Println("response = ", res.StatusCode)
+	// a transport that limits upstream requests itself is handed the
+	// limiter, so responses it answers from its cache cost nothing
+	_, transportLimits := httpClient.Transport.(upstreamLimiter)
 
-		bodyBytes, _ := ioutil.ReadAll(res.Body)
+	requestCtx := ctx
+	if transportLimits && c.Limiter != nil {
+		requestCtx = withLimiter(ctx, c.Limiter, c.clock())
+	}
 
-		var points Points
-		_ = json.Unmarshal(bodyBytes, &points)
+	request, err := http.NewRequestWithContext(requestCtx, http.MethodGet, url, nil)
 
-		if res, getErr := httpClient.Get(points.Properties.ForecastURL); getErr != nil {
-			fmt.Println("error calling GET", getErr)
-			return Forecast{}, getErr
+	if err != nil {
+		return url, "", &UpstreamError{Kind: ErrNetwork, URL: url, Err: err}
+	}
 
-		} else {
-			bodyBytes, _ := ioutil.ReadAll(res.Body)
-			bodyString := string(bodyBytes)
+	request.Header.Set("Accept", "application/geo+json")
 
-			fmt.Println(bodyString)
+	if !transportLimits {
+		release, err := c.Limiter.acquire(ctx, c.clock())
 
-			var forecast Forecast
-			_ = json.Unmarshal(bodyBytes, &forecast)
-			return forecast, nil
+		if err != nil {
+			return url, "", &UpstreamError{Kind: ErrNetwork, URL: url, Err: err}
 		}
+
+		defer release()
+	}
+
+	res, err := httpClient.Do(request)
+
+	if err != nil {
+		return url, "", &UpstreamError{Kind: ErrNetwork, URL: url, Err: err}
+	}
+
+	defer res.Body.Close()
+
+	cache := CacheStatus(res.Header.Get(CacheStatusHeader))
+
+	finalURL := url
+	if res.Request != nil {
+		finalURL = res.Request.URL.String()
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+
+	if err != nil {
+		return finalURL, cache, &UpstreamError{Kind: ErrNetwork, URL: finalURL, StatusCode: res.StatusCode, Err: err}
 	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return finalURL, cache, statusError(finalURL, res, body, c.clock().Now())
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return finalURL, cache, &UpstreamError{Kind: ErrDecode, URL: finalURL, StatusCode: res.StatusCode, Err: err}
+	}
+
+	return finalURL, cache, nil
 }
@@ -2,12 +2,14 @@ package weather
 
 import (
 	"bytes"
+	"encoding/json"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"io/ioutil"
 	"net/http"
 	"sketch-go-course/pkg/location"
 	"testing"
+	"time"
 )
 
 var mockResponse = `
@@ -393,12 +395,13 @@ func (c MockClient) RoundTrip(req *http.Request) (*http.Response, error) {
 func TestFetchForecast(t *testing.T) {
 
 	c := Client{
+		Precision: DefaultPrecision,
 		Client: &http.Client{
 			Transport: MockClient{
 				Fn: func(request *http.Request) (*http.Response, error) {
 
 					switch {
-					case request.URL.String() == "https://api.weather.gov/points/38.676026,-90.377994":
+					case request.URL.String() == "https://api.weather.gov/points/38.676,-90.378":
 						res := &http.Response{}
 						res.StatusCode = 200
 						res.Body = ioutil.NopCloser(bytes.NewReader([]byte(mockResponse)))
@@ -416,10 +419,55 @@ func TestFetchForecast(t *testing.T) {
 	}
 
 	forecast, err := c.FetchForecast(location.Coordinate{
-		Lat:  "38.676026",
-		Long: "-90.377994",
+		Lat:  38.676026,
+		Long: -90.377994,
 	})
 
 	require.NoError(t, err)
 	assert.Len(t, forecast.Properties.Periods, 14)
 }
+
+func TestSummaryIn(t *testing.T) {
+
+	var forecast Forecast
+	require.NoError(t, json.Unmarshal([]byte(mockResponse2), &forecast))
+
+	// upstream reports -04:00, so every period already falls on its own day
+	assert.Len(t, forecast.Summary().Days, 7)
+
+	// nine hours ahead of UTC Friday's periods start on Saturday, and the
+	// last period, Thursday night upstream, is Friday morning
+	tokyo := time.FixedZone("UTC+9", 9*60*60)
+	summary := forecast.SummaryIn(tokyo)
+
+	assert.Len(t, summary.Days, 7)
+	for _, day := range summary.Days {
+		assert.Equal(t, tokyo, day.Day.Location())
+		switch day.Day.Weekday() {
+		case time.Saturday:
+			assert.Equal(t, "Mostly Sunny then Scattered Rain Showers; Isolated Rain Showers then Mostly Clear; Sunny then Isolated Rain Showers", day.ShortForecast)
+		case time.Friday:
+			assert.Equal(t, 1, day.Day.Day())
+			assert.Equal(t, "Isolated Rain Showers", day.ShortForecast)
+		}
+	}
+}
+
+func TestForecastSummaryFeature(t *testing.T) {
+
+	var forecast Forecast
+	require.NoError(t, json.Unmarshal([]byte(mockResponse2), &forecast))
+
+	c := location.Coordinate{Lat: 18.180555, Long: -66.749961}
+	feature := forecast.Summary().Feature(c, map[string]interface{}{"zip": "00601"})
+
+	assert.Equal(t, []float64{c.Long, c.Lat}, feature.Geometry.Coordinates)
+	assert.Equal(t, "00601", feature.Properties["zip"])
+
+	days, ok := feature.Properties["days"].([]ForecastDay)
+	require.True(t, ok)
+	require.Len(t, days, 7)
+	for i := 1; i < len(days); i++ {
+		assert.True(t, days[i-1].Day.Before(days[i].Day))
+	}
+}